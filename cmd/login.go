@@ -15,39 +15,82 @@
 package cmd
 
 import (
+	"os"
+	"time"
+
 	"github.com/penny-vault/import-sa-quant-rank/common"
 	"github.com/penny-vault/import-sa-quant-rank/sa"
 	"github.com/playwright-community/playwright-go"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
+var loginInteractive bool
+var loginAccount string
+
 func init() {
 	rootCmd.AddCommand(loginCmd)
+
+	loginCmd.Flags().StringVar(&loginAccount, "account", "", "name of the account in seekingalpha.accounts to log in to (default is the first account)")
+	loginCmd.Flags().BoolVarP(&loginInteractive, "interactive", "i", false, "display a browser and wait for you to log in manually")
+	loginCmd.Flags().Duration("timeout", 2*time.Minute, "how long to wait for an authenticated session after submitting the login form")
+	viper.BindPFlag("login.timeout", loginCmd.Flags().Lookup("timeout"))
 }
 
 var loginCmd = &cobra.Command{
 	Use:   "login",
-	Short: "Display a browser for login",
-	Long: `The login command displays a browser for logging into Fidelity. Once logged in, the session
-state is saved to the state-file (--state-file). Fidelity sessions do expire so you will need to login
-again after the session has expired. To achieve fully automated control provide username and password
-in the configuration and do not use the login command. If you have enabled multi-factor authentication
-you will need to use this sub-command for logging in. Check the 'remember device' if you want to
-use the automated login on future runs.`,
+	Short: "Log in to Seeking Alpha and save the session",
+	Long: `The login command signs in to Seeking Alpha and saves the session state to the
+state file (--state_file). By default it signs in with a headless browser using
+the username and password configured as seekingalpha.username and
+seekingalpha.password (or the SA_USERNAME and SA_PASSWORD environment
+variables). Login succeeds once an authenticated API request returns a quant
+rating; the session is only saved when it does.
+
+Use --interactive to display a browser and log in by hand, e.g. when the
+account requires multi-factor authentication. Import runs log in again
+automatically when the session expires and credentials are configured.
+
+When several accounts are configured as [[seekingalpha.accounts]], --account
+selects the account whose credentials, proxy and state file are used.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		page, context, browser, pw := common.StartPlaywright(false)
+		account, err := sa.SelectAccount(loginAccount)
+		if err != nil {
+			log.Error().Err(err).Msg("could not load seeking alpha account")
+			os.Exit(1)
+		}
+
+		if !loginInteractive {
+			if err := sa.Login(account); err != nil {
+				log.Error().Err(err).Msg("login failed")
+				os.Exit(1)
+			}
+			return
+		}
+
+		page, context, browser, pw, err := common.StartPlaywright(false, account.StateFile, account.Proxy)
+		if err != nil {
+			log.Error().Err(err).Msg("could not start browser")
+			os.Exit(1)
+		}
 
 		// load the default homepage
-		if _, err := page.Goto(sa.HOMEPAGE_URL, playwright.PageGotoOptions{
+		if _, err := page.Goto(sa.LOGIN_PAGE_URL, playwright.PageGotoOptions{
 			WaitUntil: playwright.WaitUntilStateNetworkidle,
 		}); err != nil {
 			log.Error().Err(err).Msg("could not load login page")
 		}
 
-		// Wait for the user to press login button
-		page.WaitForTimeout(30000)
+		// Wait for the user to log in
+		log.Info().Msg("waiting for login in the browser")
+		if err := sa.WaitForSession(page, viper.GetDuration("login.timeout")); err != nil {
+			log.Error().Err(err).Msg("login failed")
+			common.ClosePlaywright(browser, pw)
+			os.Exit(1)
+		}
 
-		common.StopPlaywright(page, context, browser, pw)
+		log.Info().Msg("login succeeded")
+		common.StopPlaywright(page, context, browser, pw, account.StateFile)
 	},
 }
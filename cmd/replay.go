@@ -0,0 +1,131 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/penny-vault/import-sa-quant-rank/sa"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var replayOutputDir string
+var replayProfile string
+var replayDate string
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	replayCmd.Flags().BoolVarP(&test, "test", "t", false, "run in test mode and do not save results to database or upload to backblaze")
+	replayCmd.Flags().StringVarP(&replayOutputDir, "output", "o", "", "directory to write the parquet file to (default is a temporary directory that is removed)")
+	replayCmd.Flags().StringVar(&replayDate, "date", "", "market date (YYYY-MM-DD) to stamp the ratings with (default is the date of the archive)")
+	replayCmd.Flags().StringVar(&replayProfile, "profile", "", "screener profile used to name the output (default is the profile recorded in the archive)")
+}
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <archive>",
+	Short: "Rebuild ratings from a raw response archive",
+	Long: `The replay command rebuilds Seeking Alpha ratings from a raw response archive
+created with the --archive flag. No browser is launched and Seeking Alpha is not
+contacted. The rebuilt ratings are validated, saved to the database, written to
+parquet and uploaded to backblaze exactly like a live run.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manifest, contents, err := sa.ReadArchive(args[0])
+		if err != nil {
+			log.Error().Err(err).Msg("error reading raw response archive")
+			os.Exit(1)
+		}
+
+		date, err := replaySession(manifest)
+		if err != nil {
+			log.Error().Err(err).Msg("could not determine trading session")
+			os.Exit(1)
+		}
+
+		// archives created before screener profiles were added belong to the default profile
+		profileName := replayProfile
+		if profileName == "" {
+			profileName = manifest.Profile
+		}
+		if profileName == "" {
+			profileName = sa.DEFAULT_PROFILE
+		}
+
+		profiles, err := sa.SelectProfiles([]string{profileName})
+		if err != nil {
+			log.Error().Err(err).Msg("could not load screener profile")
+			os.Exit(1)
+		}
+		profile := profiles[0]
+
+		outputDir := replayOutputDir
+		if outputDir == "" {
+			outputDir, err = os.MkdirTemp(os.TempDir(), "import-sa")
+			if err != nil {
+				log.Error().Err(err).Msg("could not create tempdir")
+				os.Exit(1)
+			}
+			defer os.RemoveAll(outputDir)
+		}
+
+		if profile.IsEtf() {
+			err = replayUniverse[*sa.EtfRecord](manifest, contents, profile, date, outputDir)
+		} else {
+			err = replayUniverse[*sa.SeekingAlphaRecord](manifest, contents, profile, date, outputDir)
+		}
+		if err != nil {
+			log.Error().Err(err).Str("FileName", args[0]).Msg("error replaying raw response archive")
+			if replayOutputDir == "" {
+				os.RemoveAll(outputDir)
+			}
+			os.Exit(1)
+		}
+	},
+}
+
+// replaySession returns the trading session the replayed ratings are stamped
+// with; the --date flag takes precedence over the date of the archive
+func replaySession(manifest *sa.ArchiveManifest) (time.Time, error) {
+	var date time.Time
+	var err error
+	if replayDate != "" {
+		if date, err = time.Parse("2006-01-02", replayDate); err != nil {
+			return time.Time{}, fmt.Errorf("invalid --date %s: %w", replayDate, err)
+		}
+	} else if date, err = time.Parse("20060102", manifest.Date); err != nil {
+		return time.Time{}, fmt.Errorf("invalid archive date %s: %w", manifest.Date, err)
+	}
+
+	return sa.TradingSession(date)
+}
+
+func replayUniverse[R sa.Record](manifest *sa.ArchiveManifest, contents map[string][]byte, profile *sa.ScreenerProfile, date time.Time, outputDir string) error {
+	ratings, err := sa.Replay[R](manifest, contents, date)
+	if err != nil {
+		return err
+	}
+
+	if len(ratings) == 0 {
+		return errors.New("archive does not contain any ratings")
+	}
+
+	saveRatings(ratings, profile, outputDir)
+	return nil
+}
@@ -17,8 +17,10 @@ limitations under the License.
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/penny-vault/import-sa-quant-rank/backblaze"
 	"github.com/penny-vault/import-sa-quant-rank/sa"
@@ -29,6 +31,10 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// EXIT_CHALLENGE is the exit status of an import that was stopped by a
+// PerimeterX challenge that could not be solved
+const EXIT_CHALLENGE int = 3
+
 var cfgFile string
 var test bool
 
@@ -39,39 +45,195 @@ var rootCmd = &cobra.Command{
 	// Long: ``,
 	Run: func(cmd *cobra.Command, args []string) {
 		log.Info().Bool("Test", test).Msg("Download SeekingAlpha ratings")
-		ratings, err := sa.Download()
+
+		// every profile is stamped with the same trading session
+		date, err := sa.MarketTime()
+		if errors.Is(err, sa.ErrNonTradingDay) {
+			log.Info().Msg("nothing to import on a non-trading day")
+			return
+		}
 		if err != nil {
-			log.Error().Err(err).Msg("error downloading ticker metrics")
+			log.Error().Err(err).Msg("could not determine trading session")
 			os.Exit(1)
 		}
-		sa.ValidateRatings(ratings)
 
-		if !test {
-			sa.EnrichWithFigi(ratings)
-			sa.SaveToDB(ratings)
-		}
-
-		// Save data as parquet to a temporary directory
+		// Save data to a temporary directory
 		tmpdir, err := os.MkdirTemp(os.TempDir(), "import-sa")
 		if err != nil {
 			log.Error().Err(err).Msg("could not create tempdir")
 			os.Exit(1)
 		}
 
-		parquetFn := fmt.Sprintf("%s/sa-%s.parquet", tmpdir, ratings[0].Date.Format("20060102"))
-		log.Info().Str("FileName", parquetFn).Msg("writing seeking alpha ratings data to parquet")
-		sa.SaveToParquet(ratings, parquetFn)
+		tickers, err := subsetTickers()
+		if err != nil {
+			log.Error().Err(err).Msg("could not read tickers")
+			os.RemoveAll(tmpdir)
+			os.Exit(1)
+		}
+
+		// subset runs use the default profile unless profiles are selected
+		names := viper.GetStringSlice("screener.selected")
+		if len(tickers) > 0 && len(names) == 0 {
+			names = []string{sa.DEFAULT_PROFILE}
+		}
+
+		profiles, err := sa.SelectProfiles(names)
+		if err != nil {
+			log.Error().Err(err).Msg("could not load screener profiles")
+			os.RemoveAll(tmpdir)
+			os.Exit(1)
+		}
+
+		if len(tickers) > 0 {
+			for idx, profile := range profiles {
+				profiles[idx] = profile.WithTickers(tickers)
+			}
+			log.Info().Int("NumTickers", len(tickers)).Msg("skipping screener and fetching only the requested tickers")
+		}
 
-		// Upload to backblaze
-		if !test {
-			backblaze.UploadToBackBlaze(parquetFn, viper.GetString("backblaze.bucket"), ratings[0].Date.Format("2006"))
+		transport, err := sa.NewTransport()
+		if err != nil {
+			log.Error().Err(err).Msg("could not create transport")
+			os.RemoveAll(tmpdir)
+			os.Exit(1)
 		}
 
+		// each profile is its own universe; a failed profile does not prevent
+		// the remaining profiles from being imported
+		failed := false
+		challenged := false
+		for _, profile := range profiles {
+			if err := runProfile(transport, profile, date, tmpdir); err != nil {
+				log.Error().Err(err).Str("Profile", profile.Name).Msg("error downloading ticker metrics")
+				failed = true
+
+				// the remaining profiles would run into the same challenge
+				if errors.Is(err, sa.ErrChallengeUnsolved) {
+					log.Error().Int("ExitCode", EXIT_CHALLENGE).Msg("stopping import; seeking alpha challenge could not be solved")
+					challenged = true
+					break
+				}
+			}
+		}
+		transport.Close()
+
 		// Cleanup after ourselves
 		os.RemoveAll(tmpdir)
+
+		if challenged {
+			os.Exit(EXIT_CHALLENGE)
+		}
+		if failed {
+			os.Exit(1)
+		}
 	},
 }
 
+// subsetTickers returns the Seeking Alpha slugs of the tickers given with
+// --tickers and --tickers-file
+func subsetTickers() ([]string, error) {
+	tickers := viper.GetStringSlice("tickers.list")
+	fn := viper.GetString("tickers.file")
+	if fn != "" {
+		fromFile, err := sa.ReadTickersFile(fn)
+		if err != nil {
+			return nil, err
+		}
+		tickers = append(tickers, fromFile...)
+	}
+
+	slugs := sa.TickerSlugs(tickers)
+	if len(slugs) == 0 && (len(tickers) > 0 || fn != "") {
+		return nil, errors.New("no tickers given")
+	}
+	return slugs, nil
+}
+
+// runProfile downloads the ratings of a single screener profile and saves them
+func runProfile(transport sa.Transport, profile *sa.ScreenerProfile, date time.Time, tmpdir string) error {
+	if profile.IsEtf() {
+		return runUniverse[*sa.EtfRecord](transport, profile, date, tmpdir)
+	}
+	return runUniverse[*sa.SeekingAlphaRecord](transport, profile, date, tmpdir)
+}
+
+func runUniverse[R sa.Record](transport sa.Transport, profile *sa.ScreenerProfile, date time.Time, tmpdir string) error {
+	var archive *sa.Archive
+	if viper.GetBool("archive.enabled") {
+		archiveFn := profile.ArchiveFile(viper.GetString("checkpoint.file"), date)
+		log.Info().Str("FileName", archiveFn).Msg("archiving raw seeking alpha responses")
+
+		var err error
+		if viper.GetBool("resume") {
+			archive, err = sa.OpenArchive(archiveFn, date, profile.Name)
+		} else {
+			archive, err = sa.NewArchive(archiveFn, date, profile.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	ratings, err := sa.Download[R](transport, archive, profile, date)
+	if archive != nil {
+		if closeErr := archive.Close(); closeErr != nil {
+			log.Error().Err(closeErr).Msg("could not close raw response archive")
+		} else if err != nil {
+			// keep the archive with the checkpoint; a resumed run appends to it
+			log.Info().Str("FileName", archive.FileName).Msg("keeping raw response archive of failed download")
+		} else {
+			if !test {
+				backblaze.UploadToBackBlaze(archive.FileName, viper.GetString("backblaze.bucket"), date.Format("2006"))
+			}
+			archive.Remove()
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(ratings) == 0 {
+		log.Warn().Str("Profile", profile.Name).Msg("screener profile did not return any ratings")
+		return nil
+	}
+
+	saveRatings(ratings, profile, tmpdir)
+	return nil
+}
+
+// saveRatings validates the downloaded ratings and saves them to the database,
+// a parquet file in tmpdir and backblaze. Stocks are saved to the
+// seeking_alpha table and ETFs to seeking_alpha_etf.
+func saveRatings[R sa.Record](ratings []R, profile *sa.ScreenerProfile, tmpdir string) {
+	saveToDB := !test && !profile.SkipDatabase
+	if saveToDB {
+		sa.EnrichWithFigi(ratings)
+	}
+
+	switch records := any(ratings).(type) {
+	case []*sa.SeekingAlphaRecord:
+		sa.ValidateRatings(records)
+		if saveToDB {
+			sa.SaveToDB(records)
+		}
+	case []*sa.EtfRecord:
+		sa.ValidateEtfRatings(records)
+		if saveToDB {
+			sa.SaveEtfsToDB(records)
+		}
+	}
+
+	date := ratings[0].EventDate()
+	parquetFn := fmt.Sprintf("%s/%s", tmpdir, profile.FileName(date, ".parquet"))
+	log.Info().Str("FileName", parquetFn).Msg("writing seeking alpha ratings data to parquet")
+	sa.SaveToParquet(ratings, parquetFn)
+
+	// Upload to backblaze
+	if !test {
+		backblaze.UploadToBackBlaze(parquetFn, viper.GetString("backblaze.bucket"), date.Format("2006"))
+	}
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -98,21 +260,106 @@ func init() {
 	rootCmd.PersistentFlags().String("state_file", "state.json", "state file")
 	viper.BindPFlag("playwright.state_file", rootCmd.PersistentFlags().Lookup("state_file"))
 
+	rootCmd.PersistentFlags().String("state-key-file", "", "file holding the base64 encoded key used to encrypt the state file (or set SA_STATE_KEY)")
+	viper.BindPFlag("state.key_file", rootCmd.PersistentFlags().Lookup("state-key-file"))
+	viper.BindEnv("state.key", "SA_STATE_KEY")
+
+	viper.BindEnv("seekingalpha.username", "SA_USERNAME")
+	viper.BindEnv("seekingalpha.password", "SA_PASSWORD")
+
+	rootCmd.Flags().Bool("auto-login", true, "log in again with the configured credentials when the session expires")
+	viper.BindPFlag("login.auto", rootCmd.Flags().Lookup("auto-login"))
+
+	rootCmd.Flags().String("account-rotation", sa.ROTATION_PAGE, "how requests are spread across seekingalpha.accounts: page (next account every screener page) or failover (next account when blocked)")
+	viper.BindPFlag("seekingalpha.rotation", rootCmd.Flags().Lookup("account-rotation"))
+
+	rootCmd.Flags().String("account-usage-file", "", "file that accumulates the request counts of each account across runs; the least used account goes first")
+	viper.BindPFlag("seekingalpha.usage_file", rootCmd.Flags().Lookup("account-usage-file"))
+
+	rootCmd.Flags().Int("challenge-attempts", 3, "number of times a PerimeterX challenge is solved before the import exits with status 3")
+	viper.BindPFlag("challenge.max_attempts", rootCmd.Flags().Lookup("challenge-attempts"))
+
+	rootCmd.Flags().Duration("challenge-hold", 15*time.Second, "how long the PerimeterX press and hold button is held before the attempt fails")
+	viper.BindPFlag("challenge.hold_timeout", rootCmd.Flags().Lookup("challenge-hold"))
+
+	rootCmd.Flags().Bool("preflight", true, "check that the session is entitled to quant ratings before downloading each profile")
+	viper.BindPFlag("session.preflight", rootCmd.Flags().Lookup("preflight"))
+
 	// Add flags
-	rootCmd.Flags().StringP("database_url", "d", "host=localhost port=5432", "DSN for database connection")
-	viper.BindPFlag("database.url", rootCmd.Flags().Lookup("database_url"))
+	rootCmd.PersistentFlags().StringP("database_url", "d", "host=localhost port=5432", "DSN for database connection")
+	viper.BindPFlag("database.url", rootCmd.PersistentFlags().Lookup("database_url"))
+
+	rootCmd.PersistentFlags().String("catalog", "", "metric catalog file that extends or overrides the built-in catalog")
+	viper.BindPFlag("catalog.file", rootCmd.PersistentFlags().Lookup("catalog"))
+
+	rootCmd.PersistentFlags().String("calendar", "", "trading calendar file that adds holidays to the built-in NYSE calendar")
+	viper.BindPFlag("calendar.file", rootCmd.PersistentFlags().Lookup("calendar"))
+
+	rootCmd.PersistentFlags().String("non-trading-day", sa.NON_TRADING_DAY_SKIP, "what to do when the NYSE is closed: skip, previous (stamp with the previous session) or next (stamp with the next session)")
+	viper.BindPFlag("calendar.non_trading_day", rootCmd.PersistentFlags().Lookup("non-trading-day"))
+
+	rootCmd.PersistentFlags().String("drift-severity", sa.DRIFT_SEVERITY_WARN, "how schema drift in Seeking Alpha responses is reported: ignore, warn or error (fails the run)")
+	viper.BindPFlag("drift.severity", rootCmd.PersistentFlags().Lookup("drift-severity"))
+
+	rootCmd.Flags().Bool("archive", false, "archive every raw response from Seeking Alpha next to the checkpoint and upload it with the parquet file")
+	viper.BindPFlag("archive.enabled", rootCmd.Flags().Lookup("archive"))
+
+	rootCmd.Flags().String("transport", "playwright", "transport used to call the Seeking Alpha API: playwright, http, or auto (http that falls back to playwright when blocked)")
+	viper.BindPFlag("transport", rootCmd.Flags().Lookup("transport"))
+
+	rootCmd.Flags().Bool("resume", false, "resume from the last completed page of an interrupted run for the same market date")
+	viper.BindPFlag("resume", rootCmd.Flags().Lookup("resume"))
+
+	rootCmd.Flags().String("checkpoint", "sa-checkpoint.json", "file that records completed pages so an interrupted run can be resumed; the profile name is appended to it")
+	viper.BindPFlag("checkpoint.file", rootCmd.Flags().Lookup("checkpoint"))
+
+	rootCmd.Flags().Int("max-attempts", 5, "maximum number of attempts for each Seeking Alpha request")
+	viper.BindPFlag("retry.max_attempts", rootCmd.Flags().Lookup("max-attempts"))
+
+	rootCmd.Flags().Duration("initial-backoff", time.Second, "delay before the first retry of a failed request; doubles on each subsequent retry")
+	viper.BindPFlag("retry.initial_backoff", rootCmd.Flags().Lookup("initial-backoff"))
+
+	rootCmd.Flags().Duration("max-backoff", time.Minute, "maximum delay between retries of a failed request")
+	viper.BindPFlag("retry.max_backoff", rootCmd.Flags().Lookup("max-backoff"))
+
+	rootCmd.Flags().Float64("rps", 6.5, "maximum number of requests per second sent to Seeking Alpha; 0 disables rate limiting")
+	viper.BindPFlag("ratelimit.rps", rootCmd.Flags().Lookup("rps"))
+
+	rootCmd.Flags().Int("burst", 1, "number of requests that may be sent back-to-back before rate limiting applies")
+	viper.BindPFlag("ratelimit.burst", rootCmd.Flags().Lookup("burst"))
+
+	rootCmd.Flags().Duration("slow-latency", 5*time.Second, "response time above which the request rate is reduced")
+	viper.BindPFlag("ratelimit.slow_latency", rootCmd.Flags().Lookup("slow-latency"))
+
+	rootCmd.Flags().Int("restart-interval", 25, "restart the browser every N screener pages even when it is healthy; 0 disables")
+	viper.BindPFlag("playwright.restart_interval", rootCmd.Flags().Lookup("restart-interval"))
+
+	rootCmd.Flags().Duration("request-timeout", 30*time.Second, "time a browser page may take to respond before it is considered hung and the browser is restarted")
+	viper.BindPFlag("playwright.request_timeout", rootCmd.Flags().Lookup("request-timeout"))
+
+	rootCmd.Flags().Int("workers", 1, "number of metrics requests made concurrently for each screener page")
+	viper.BindPFlag("workers", rootCmd.Flags().Lookup("workers"))
+
+	rootCmd.Flags().StringSlice("profile", []string{}, "screener profiles to download (default is every profile)")
+	viper.BindPFlag("screener.selected", rootCmd.Flags().Lookup("profile"))
+
+	rootCmd.Flags().StringSlice("tickers", []string{}, "skip the screener and only fetch these tickers, e.g. AAPL,BRK/B")
+	viper.BindPFlag("tickers.list", rootCmd.Flags().Lookup("tickers"))
+
+	rootCmd.Flags().String("tickers-file", "", "skip the screener and only fetch the tickers listed in this file (comma or new line separated)")
+	viper.BindPFlag("tickers.file", rootCmd.Flags().Lookup("tickers-file"))
 
 	rootCmd.Flags().Uint32P("limit", "l", 0, "limit results to N")
 	viper.BindPFlag("limit", rootCmd.Flags().Lookup("limit"))
 
-	rootCmd.Flags().StringP("backblaze_bucket", "b", "seeking-alpha", "Backblaze bucket name")
-	viper.BindPFlag("backblaze.bucket", rootCmd.Flags().Lookup("backblaze_bucket"))
+	rootCmd.PersistentFlags().StringP("backblaze_bucket", "b", "seeking-alpha", "Backblaze bucket name")
+	viper.BindPFlag("backblaze.bucket", rootCmd.PersistentFlags().Lookup("backblaze_bucket"))
 
-	rootCmd.Flags().String("backblaze_application_id", "<not-set>", "Backblaze application id")
-	viper.BindPFlag("backblaze.application_id", rootCmd.Flags().Lookup("backblaze_application_id"))
+	rootCmd.PersistentFlags().String("backblaze_application_id", "<not-set>", "Backblaze application id")
+	viper.BindPFlag("backblaze.application_id", rootCmd.PersistentFlags().Lookup("backblaze_application_id"))
 
-	rootCmd.Flags().String("backblaze_application_key", "<not-set>", "Backblaze application key")
-	viper.BindPFlag("backblaze.application_key", rootCmd.Flags().Lookup("backblaze_application_key"))
+	rootCmd.PersistentFlags().String("backblaze_application_key", "<not-set>", "Backblaze application key")
+	viper.BindPFlag("backblaze.application_key", rootCmd.PersistentFlags().Lookup("backblaze_application_key"))
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -0,0 +1,106 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"time"
+
+	"github.com/penny-vault/import-sa-quant-rank/common"
+	"github.com/penny-vault/import-sa-quant-rank/sa"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(sessionCmd)
+	sessionCmd.AddCommand(sessionStatusCmd)
+
+	sessionStatusCmd.Flags().String("account", "", "name of the account in seekingalpha.accounts to check (default is the first account)")
+	sessionStatusCmd.Flags().String("transport", "", "transport used for the entitlement check (default is the configured transport)")
+}
+
+var sessionCmd = &cobra.Command{
+	Use:   "session",
+	Short: "Inspect the saved Seeking Alpha session",
+}
+
+var sessionStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Report cookie expiry and check that the session has premium access",
+	Long: `The status command reads the state file of an account (--state_file unless
+--account selects one of seekingalpha.accounts), reports when each Seeking
+Alpha cookie expires and requests the quant rating of a known ticker to
+confirm that the session is entitled to quant ratings. It exits with a non-zero
+status when the state file cannot be read or the session is not entitled.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		accountName, _ := cmd.Flags().GetString("account")
+		account, err := sa.SelectAccount(accountName)
+		if err != nil {
+			log.Error().Err(err).Msg("could not load seeking alpha account")
+			os.Exit(1)
+		}
+
+		stateFile := account.StateFile
+		state, err := common.ReadSessionState(stateFile)
+		if err != nil {
+			log.Error().Err(err).Str("StateFile", stateFile).Msg("could not read state file")
+			os.Exit(1)
+		}
+
+		now := time.Now()
+		cookies := sa.SessionCookies(state)
+		if len(cookies) == 0 {
+			log.Warn().Str("StateFile", stateFile).Msg("state file has no seeking alpha cookies")
+		}
+
+		for _, cookie := range cookies {
+			event := log.Info()
+			if cookie.Expired(now) {
+				event = log.Warn()
+			}
+
+			event = event.Str("Name", cookie.Name).Str("Domain", cookie.Domain)
+			if cookie.Expires.IsZero() {
+				event.Msg("session cookie")
+				continue
+			}
+			event.Time("Expires", cookie.Expires).Bool("Expired", cookie.Expired(now)).Dur("Remaining", cookie.Expires.Sub(now).Truncate(time.Second)).Msg("cookie")
+		}
+
+		if state.UserAgent == "" {
+			log.Warn().Msg("state file does not record a user agent")
+		} else {
+			log.Info().Str("UserAgent", state.UserAgent).Msg("user agent")
+		}
+
+		if transportName, _ := cmd.Flags().GetString("transport"); transportName != "" {
+			viper.Set("transport", transportName)
+		}
+
+		transport, err := sa.NewAccountTransport(account)
+		if err != nil {
+			log.Error().Err(err).Msg("could not create transport")
+			os.Exit(1)
+		}
+
+		err = sa.CheckEntitlement(transport)
+		transport.Close()
+		if err != nil {
+			os.Exit(1)
+		}
+	},
+}
@@ -0,0 +1,252 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/penny-vault/import-sa-quant-rank/common"
+	"github.com/penny-vault/import-sa-quant-rank/sa"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var stateAccount string
+var stateDecryptOutput string
+var stateNewKeyFile string
+
+func init() {
+	rootCmd.AddCommand(stateCmd)
+	stateCmd.AddCommand(stateEncryptCmd)
+	stateCmd.AddCommand(stateDecryptCmd)
+	stateCmd.AddCommand(stateInspectCmd)
+	stateCmd.AddCommand(stateRotateCmd)
+	stateCmd.AddCommand(stateKeygenCmd)
+
+	stateCmd.PersistentFlags().StringVar(&stateAccount, "account", "", "name of the account in seekingalpha.accounts whose state file is used (default is the first account)")
+	stateDecryptCmd.Flags().StringVarP(&stateDecryptOutput, "output", "o", "", "file to write the decrypted state to (default is to replace the state file)")
+	stateRotateCmd.Flags().StringVar(&stateNewKeyFile, "new-key-file", "", "file holding the new key; a key is generated and written to it if it does not exist")
+	stateRotateCmd.MarkFlagRequired("new-key-file")
+}
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Manage encryption of the browser state file",
+	Long: `The state file (--state_file) holds the Seeking Alpha session cookies. When a
+state key is configured, either as a base64 encoded 32 byte key in the
+SA_STATE_KEY environment variable or in a file given with --state-key-file, the
+state file is encrypted with AES-256-GCM whenever it is saved. Plaintext state
+files are still read so that they can be migrated with 'state encrypt'.
+
+When several accounts are configured as [[seekingalpha.accounts]], --account
+selects the account whose state file is managed.`,
+}
+
+var stateEncryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt the state file with the configured key",
+	Run: func(cmd *cobra.Command, args []string) {
+		stateFile := accountStateFile()
+		key := requireStateKey()
+
+		data, err := common.ReadStateFileWithKey(stateFile, key)
+		if err != nil {
+			log.Error().Err(err).Str("StateFile", stateFile).Msg("could not read state file")
+			os.Exit(1)
+		}
+
+		if err := writeState(stateFile, data, key); err != nil {
+			os.Exit(1)
+		}
+
+		log.Info().Str("StateFile", stateFile).Msg("state file encrypted")
+	},
+}
+
+var stateDecryptCmd = &cobra.Command{
+	Use:   "decrypt",
+	Short: "Decrypt the state file and store it as plaintext",
+	Run: func(cmd *cobra.Command, args []string) {
+		stateFile := accountStateFile()
+		data, err := common.ReadStateFile(stateFile)
+		if err != nil {
+			log.Error().Err(err).Str("StateFile", stateFile).Msg("could not read state file")
+			os.Exit(1)
+		}
+
+		outputFile := stateDecryptOutput
+		if outputFile == "" {
+			outputFile = stateFile
+		}
+
+		if err := writeState(outputFile, data, nil); err != nil {
+			os.Exit(1)
+		}
+
+		log.Warn().Str("StateFile", outputFile).Msg("state file stored as plaintext")
+	},
+}
+
+var stateInspectCmd = &cobra.Command{
+	Use:   "inspect",
+	Short: "Report the format of the state file without printing its contents",
+	Run: func(cmd *cobra.Command, args []string) {
+		stateFile := accountStateFile()
+		raw, err := os.ReadFile(stateFile)
+		if err != nil {
+			log.Error().Err(err).Str("StateFile", stateFile).Msg("could not read state file")
+			os.Exit(1)
+		}
+
+		key, keyErr := common.StateKey()
+		if keyErr != nil {
+			log.Error().Err(keyErr).Msg("could not load state key")
+		}
+
+		event := log.Info().Str("StateFile", stateFile).Int("Size", len(raw)).Bool("KeyConfigured", key != nil)
+		if info, err := os.Stat(stateFile); err == nil {
+			event = event.Str("Mode", info.Mode().Perm().String())
+		}
+		if version, err := common.StateVersion(raw); err == nil {
+			event.Str("Format", "encrypted").Uint8("Version", version).Msg("state file")
+		} else {
+			event.Str("Format", "plaintext").Msg("state file")
+		}
+
+		data, err := common.ReadStateFileWithKey(stateFile, key)
+		if err != nil {
+			log.Error().Err(err).Msg("could not read state")
+			os.Exit(1)
+		}
+
+		state := &common.SessionState{}
+		if err := json.Unmarshal(data, state); err != nil {
+			log.Error().Err(err).Msg("state file is not a valid browser state")
+			os.Exit(1)
+		}
+
+		log.Info().Int("NumCookies", len(state.Cookies)).Int("NumOrigins", len(state.Origins)).Str("UserAgent", state.UserAgent).Msg("browser state")
+	},
+}
+
+var stateRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Re-encrypt the state file with a new key",
+	Long: `The rotate command decrypts the state file with the configured key and
+encrypts it again with the key in --new-key-file, generating the key first if
+the file does not exist. Point --state-key-file (or SA_STATE_KEY) at the new
+key afterwards.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		stateFile := accountStateFile()
+		data, err := common.ReadStateFile(stateFile)
+		if err != nil {
+			log.Error().Err(err).Str("StateFile", stateFile).Msg("could not read state file")
+			os.Exit(1)
+		}
+
+		newKey, err := loadOrCreateStateKey(stateNewKeyFile)
+		if err != nil {
+			log.Error().Err(err).Str("KeyFile", stateNewKeyFile).Msg("could not load new state key")
+			os.Exit(1)
+		}
+
+		if err := writeState(stateFile, data, newKey); err != nil {
+			os.Exit(1)
+		}
+
+		log.Info().Str("StateFile", stateFile).Str("KeyFile", stateNewKeyFile).Msg("state file encrypted with the new key; update the configured state key")
+	},
+}
+
+var stateKeygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Print a new base64 encoded state key",
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := common.GenerateStateKey()
+		if err != nil {
+			log.Error().Err(err).Msg("could not generate state key")
+			os.Exit(1)
+		}
+		fmt.Println(key)
+	},
+}
+
+// accountStateFile returns the state file of the account selected with
+// --account and exits if the account cannot be loaded
+func accountStateFile() string {
+	account, err := sa.SelectAccount(stateAccount)
+	if err != nil {
+		log.Error().Err(err).Msg("could not load seeking alpha account")
+		os.Exit(1)
+	}
+
+	return account.StateFile
+}
+
+// requireStateKey returns the configured state key and exits if there is none
+func requireStateKey() []byte {
+	key, err := common.StateKey()
+	if err != nil {
+		log.Error().Err(err).Msg("could not load state key")
+		os.Exit(1)
+	}
+
+	if key == nil {
+		log.Error().Msg("no state key configured; set SA_STATE_KEY or --state-key-file")
+		os.Exit(1)
+	}
+
+	return key
+}
+
+// writeState checks that data is a browser state before saving it to fn
+func writeState(fn string, data []byte, key []byte) error {
+	state := &common.SessionState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		log.Error().Err(err).Msg("state file is not a valid browser state")
+		return err
+	}
+
+	if err := common.WriteStateFileWithKey(fn, data, key); err != nil {
+		log.Error().Err(err).Str("StateFile", fn).Msg("could not write state file")
+		return err
+	}
+
+	return nil
+}
+
+// loadOrCreateStateKey reads the state key in fn, generating a new key and
+// saving it to fn if the file does not exist
+func loadOrCreateStateKey(fn string) ([]byte, error) {
+	key, err := common.ReadStateKeyFile(fn)
+	if !errors.Is(err, os.ErrNotExist) {
+		return key, err
+	}
+
+	encoded, err := common.GenerateStateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(fn, []byte(encoded+"\n"), 0600); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("KeyFile", fn).Msg("generated new state key")
+	return common.ParseStateKey(encoded)
+}
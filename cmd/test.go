@@ -16,11 +16,7 @@ package cmd
 
 import (
 	"bufio"
-	"bytes"
 	"fmt"
-	"image/color"
-	"image/jpeg"
-	"math/rand"
 	"os"
 	"strings"
 	"time"
@@ -30,6 +26,7 @@ import (
 	"github.com/playwright-community/playwright-go"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // testCmd represents the test command
@@ -40,7 +37,12 @@ var testCmd = &cobra.Command{
 allows users to query for a selector and view the bounding box coordinates of
 the DOM object, issue mouse move / click events, and exit.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		page, context, browser, pw := common.StartPlaywright(false)
+		stateFile := viper.GetString("playwright.state_file")
+		page, context, browser, pw, err := common.StartPlaywright(false, stateFile, viper.GetString("playwright.proxy"))
+		if err != nil {
+			log.Error().Err(err).Msg("could not start browser")
+			os.Exit(1)
+		}
 
 		// load the default homepage
 		if _, err := page.Goto("https://bot.incolumitas.com", playwright.PageGotoOptions{
@@ -157,7 +159,9 @@ the DOM object, issue mouse move / click events, and exit.`,
 					fmt.Printf("Screenshot took %d ms\n", dur.Milliseconds())
 				}
 			case "6": // solve human captcha
-				solveCaptcha(page)
+				if err := common.SolveCaptcha(page, viper.GetDuration("challenge.hold_timeout")); err != nil {
+					log.Error().Err(err).Msg("could not solve captcha")
+				}
 			case "e":
 				log.Info().Msg("exiting...")
 			default:
@@ -166,82 +170,10 @@ the DOM object, issue mouse move / click events, and exit.`,
 			}
 		}
 
-		common.StopPlaywright(page, context, browser, pw)
+		common.StopPlaywright(page, context, browser, pw, stateFile)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(testCmd)
 }
-
-func solveCaptcha(page playwright.Page) {
-	// get the px-captcha element
-	sel, err := page.QuerySelector("#px-captcha")
-	if err != nil {
-		log.Error().Err(err).Msg("failed getting selector")
-		return
-	}
-
-	if sel == nil {
-		log.Info().Msg("selector not found!")
-		return
-	}
-
-	bbox, err := sel.BoundingBox()
-	if err != nil {
-		log.Error().Err(err).Msg("could not get bounding box of object")
-		return
-	}
-
-	// select a random point on the screen to begin
-	xBegin := rand.Intn(200)
-	yBegin := rand.Intn(100)
-
-	page.Mouse().Move(float64(xBegin), float64(yBegin))
-	time.Sleep(time.Second)
-
-	// select a random point somewhere in the middle-ish of the button to end
-	xEnd := rand.Intn(200) + 50 + int(bbox.X)
-	yEnd := rand.Intn(60) + 20 + int(bbox.Y)
-
-	page.Mouse().Move(float64(xEnd), float64(yEnd))
-	dur := time.Millisecond * time.Duration(rand.Intn(200))
-	time.Sleep(dur)
-	page.Mouse().Down()
-
-	isSolved := false
-	for !isSolved {
-		screenshot, err := sel.Screenshot(playwright.ElementHandleScreenshotOptions{
-			Type: playwright.ScreenshotTypeJpeg,
-		})
-		if err != nil {
-			log.Error().Err(err).Msg("failed to capture element screenshot")
-			return
-		}
-
-		buf := bytes.NewBuffer(screenshot)
-		img, err := jpeg.Decode(buf)
-		if err != nil {
-			log.Error().Err(err).Msg("cannot decode image")
-			return
-		}
-
-		c := img.At(300, 50)
-		white := color.RGBA{255, 255, 255, 255}
-		filled := color.RGBA{57, 57, 57, 255}
-
-		switch c {
-		case white:
-			// not yet solved
-			log.Info().Msg("captcha not-yet solved")
-		case filled:
-			isSolved = true
-			log.Info().Msg("captcha solved!")
-		default:
-			log.Info().Msg("unknown color found; assuming captcha is not yet solved")
-		}
-	}
-
-	time.Sleep(20 * time.Millisecond)
-	page.Mouse().Up()
-}
@@ -0,0 +1,161 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"image/jpeg"
+	"math/rand"
+	"time"
+
+	"github.com/playwright-community/playwright-go"
+	"github.com/rs/zerolog/log"
+)
+
+// CAPTCHA_SELECTOR selects the PerimeterX press and hold button
+const CAPTCHA_SELECTOR string = "#px-captcha"
+
+var (
+	// ErrCaptchaNotFound is returned when the page has no PerimeterX button
+	ErrCaptchaNotFound = errors.New("perimeterx captcha not found")
+
+	// ErrCaptchaTimeout is returned when the button did not fill while it
+	// was held
+	ErrCaptchaTimeout = errors.New("perimeterx captcha was not solved before the hold timeout")
+)
+
+// HasCaptcha returns true if page displays the PerimeterX press and hold button
+func HasCaptcha(page playwright.Page) bool {
+	sel, err := page.QuerySelector(CAPTCHA_SELECTOR)
+	return err == nil && sel != nil
+}
+
+// SolveCaptcha presses and holds the PerimeterX button until it fills with
+// color, giving up after holdTimeout (15 seconds if it is not positive)
+func SolveCaptcha(page playwright.Page, holdTimeout time.Duration) error {
+	if holdTimeout <= 0 {
+		holdTimeout = 15 * time.Second
+	}
+
+	// get the px-captcha element
+	sel, err := page.QuerySelector(CAPTCHA_SELECTOR)
+	if err != nil {
+		log.Error().Err(err).Msg("failed getting selector")
+		return err
+	}
+
+	if sel == nil {
+		return ErrCaptchaNotFound
+	}
+
+	bbox, err := sel.BoundingBox()
+	if err != nil {
+		log.Error().Err(err).Msg("could not get bounding box of object")
+		return err
+	}
+
+	// select a random point on the screen to begin
+	xBegin := rand.Intn(200)
+	yBegin := rand.Intn(100)
+
+	page.Mouse().Move(float64(xBegin), float64(yBegin))
+	time.Sleep(time.Second)
+
+	// select a random point somewhere in the middle-ish of the button to end
+	xEnd := rand.Intn(200) + 50 + int(bbox.X)
+	yEnd := rand.Intn(60) + 20 + int(bbox.Y)
+
+	page.Mouse().Move(float64(xEnd), float64(yEnd))
+	dur := time.Millisecond * time.Duration(rand.Intn(200))
+	time.Sleep(dur)
+	if err := page.Mouse().Down(); err != nil {
+		log.Error().Err(err).Msg("mouse down failed")
+		return err
+	}
+
+	solved, err := waitForCaptchaFill(sel, holdTimeout)
+
+	time.Sleep(20 * time.Millisecond)
+	page.Mouse().Up()
+
+	if err != nil {
+		return err
+	}
+	if !solved {
+		return ErrCaptchaTimeout
+	}
+
+	log.Info().Msg("captcha solved!")
+	return nil
+}
+
+// waitForCaptchaFill polls a screenshot of the button until it is filled or
+// timeout elapses
+func waitForCaptchaFill(sel playwright.ElementHandle, timeout time.Duration) (bool, error) {
+	filled := color.RGBA{57, 57, 57, 255}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		screenshot, err := sel.Screenshot(playwright.ElementHandleScreenshotOptions{
+			Type: playwright.ScreenshotTypeJpeg,
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("failed to capture element screenshot")
+			return false, err
+		}
+
+		img, err := jpeg.Decode(bytes.NewBuffer(screenshot))
+		if err != nil {
+			log.Error().Err(err).Msg("cannot decode image")
+			return false, err
+		}
+
+		// sample a point inside the button, clamped to the screenshot
+		bounds := img.Bounds()
+		x, y := 300, 50
+		if x >= bounds.Max.X {
+			x = bounds.Max.X - 1
+		}
+		if y >= bounds.Max.Y {
+			y = bounds.Max.Y - 1
+		}
+
+		if similarColor(img.At(x, y), filled) {
+			return true, nil
+		}
+		log.Debug().Msg("captcha not-yet solved")
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return false, nil
+}
+
+// similarColor returns true if a and b differ by at most a small amount in
+// every channel; jpeg screenshots do not reproduce colors exactly
+func similarColor(a, b color.Color) bool {
+	const tolerance = 24 << 8
+
+	ar, ag, ab, _ := a.RGBA()
+	br, bg, bb, _ := b.RGBA()
+	for _, diff := range []int64{int64(ar) - int64(br), int64(ag) - int64(bg), int64(ab) - int64(bb)} {
+		if diff > tolerance || diff < -tolerance {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,175 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Encrypted state files start with STATE_MAGIC followed by a one byte format
+// version. Version 1 is followed by a 12 byte nonce and the AES-256-GCM
+// sealed state; the magic and version are authenticated as additional data.
+const (
+	STATE_MAGIC   string = "PVSTATE"
+	STATE_VERSION byte   = 1
+)
+
+// STATE_KEY_SIZE is the length of a state key in bytes (AES-256)
+const STATE_KEY_SIZE int = 32
+
+var (
+	// ErrNoStateKey is returned when an encrypted state file is read but no
+	// key is configured
+	ErrNoStateKey = errors.New("state file is encrypted but no state key is configured")
+
+	// ErrStateVersion is returned for encrypted state files written by a newer
+	// version of the format
+	ErrStateVersion = errors.New("unsupported state file version")
+)
+
+// IsEncryptedState returns true if data starts with the encrypted state header
+func IsEncryptedState(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(STATE_MAGIC))
+}
+
+// StateVersion returns the format version of an encrypted state file
+func StateVersion(data []byte) (byte, error) {
+	if !IsEncryptedState(data) || len(data) <= len(STATE_MAGIC) {
+		return 0, errors.New("state file is not encrypted")
+	}
+	return data[len(STATE_MAGIC)], nil
+}
+
+// EncryptState seals plaintext with key and prepends the versioned header
+func EncryptState(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newStateCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := stateHeader()
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	// dst must not share memory with the additional data
+	out := make([]byte, 0, len(header)+len(nonce)+len(plaintext)+gcm.Overhead())
+	out = append(out, header...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plaintext, header), nil
+}
+
+// DecryptState opens an encrypted state file with key
+func DecryptState(key, data []byte) ([]byte, error) {
+	version, err := StateVersion(data)
+	if err != nil {
+		return nil, err
+	}
+	if version != STATE_VERSION {
+		return nil, fmt.Errorf("%w: %d", ErrStateVersion, version)
+	}
+
+	gcm, err := newStateCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	headerLen := len(STATE_MAGIC) + 1
+	if len(data) < headerLen+gcm.NonceSize()+gcm.Overhead() {
+		return nil, errors.New("encrypted state file is truncated")
+	}
+
+	header := data[:headerLen]
+	nonce := data[headerLen : headerLen+gcm.NonceSize()]
+	plaintext, err := gcm.Open(nil, nonce, data[headerLen+gcm.NonceSize():], header)
+	if err != nil {
+		return nil, errors.New("could not decrypt state file; the key is wrong or the file was modified")
+	}
+
+	return plaintext, nil
+}
+
+func stateHeader() []byte {
+	return append([]byte(STATE_MAGIC), STATE_VERSION)
+}
+
+func newStateCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) != STATE_KEY_SIZE {
+		return nil, fmt.Errorf("state key must be %d bytes, got %d", STATE_KEY_SIZE, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// GenerateStateKey returns a new random state key encoded as base64
+func GenerateStateKey() (string, error) {
+	key := make([]byte, STATE_KEY_SIZE)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ParseStateKey decodes a base64 encoded state key
+func ParseStateKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("state key is not valid base64: %w", err)
+	}
+	if len(key) != STATE_KEY_SIZE {
+		return nil, fmt.Errorf("state key must be %d bytes, got %d", STATE_KEY_SIZE, len(key))
+	}
+	return key, nil
+}
+
+// ReadStateKeyFile reads a base64 encoded state key from fn
+func ReadStateKeyFile(fn string) ([]byte, error) {
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	return ParseStateKey(string(data))
+}
+
+// StateKey returns the configured state key: state.key (SA_STATE_KEY) or the
+// contents of state.key_file. It returns nil when neither is set, in which
+// case the state file is stored as plaintext.
+func StateKey() ([]byte, error) {
+	if encoded := viper.GetString("state.key"); encoded != "" {
+		return ParseStateKey(encoded)
+	}
+
+	if fn := viper.GetString("state.key_file"); fn != "" {
+		return ReadStateKeyFile(fn)
+	}
+
+	return nil, nil
+}
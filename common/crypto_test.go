@@ -0,0 +1,153 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testState = `{"cookies":[{"name":"session_token","value":"abc"}],"origins":[]}`
+
+func newTestStateKey(t *testing.T) []byte {
+	t.Helper()
+
+	encoded, err := GenerateStateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ParseStateKey(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func TestEncryptStateRoundTrip(t *testing.T) {
+	key := newTestStateKey(t)
+
+	data, err := EncryptState(key, []byte(testState))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsEncryptedState(data) {
+		t.Fatal("encrypted state does not start with the state header")
+	}
+	if bytes.Contains(data, []byte("session_token")) {
+		t.Fatal("encrypted state contains the plaintext")
+	}
+
+	version, err := StateVersion(data)
+	if err != nil || version != STATE_VERSION {
+		t.Fatalf("expected version %d, got %d (%v)", STATE_VERSION, version, err)
+	}
+
+	plaintext, err := DecryptState(key, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != testState {
+		t.Fatalf("expected %s, got %s", testState, plaintext)
+	}
+}
+
+func TestDecryptStateWrongKey(t *testing.T) {
+	data, err := EncryptState(newTestStateKey(t), []byte(testState))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := DecryptState(newTestStateKey(t), data); err == nil {
+		t.Fatal("expected decrypting with the wrong key to fail")
+	}
+}
+
+func TestDecryptStateTampered(t *testing.T) {
+	key := newTestStateKey(t)
+	data, err := EncryptState(key, []byte(testState))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a changed version byte is rejected; it is also authenticated as
+	// additional data so it cannot be changed to another supported version
+	tampered := bytes.Clone(data)
+	tampered[len(STATE_MAGIC)]++
+	if _, err := DecryptState(key, tampered); !errors.Is(err, ErrStateVersion) {
+		t.Fatalf("expected ErrStateVersion for a tampered version, got %v", err)
+	}
+
+	tampered = bytes.Clone(data)
+	tampered[len(tampered)-1] ^= 0xff
+	if _, err := DecryptState(key, tampered); err == nil {
+		t.Fatal("expected decrypting a tampered ciphertext to fail")
+	}
+
+	if _, err := DecryptState(key, data[:len(STATE_MAGIC)+4]); err == nil {
+		t.Fatal("expected decrypting a truncated state to fail")
+	}
+}
+
+func TestReadStateFileWithKey(t *testing.T) {
+	dir := t.TempDir()
+	key := newTestStateKey(t)
+
+	// plaintext state files are read whether or not a key is configured
+	plainFn := filepath.Join(dir, "plain.json")
+	if err := os.WriteFile(plainFn, []byte(testState), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range [][]byte{nil, key} {
+		data, err := ReadStateFileWithKey(plainFn, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != testState {
+			t.Fatalf("expected %s, got %s", testState, data)
+		}
+	}
+
+	encryptedFn := filepath.Join(dir, "encrypted.json")
+	if err := WriteStateFileWithKey(encryptedFn, []byte(testState), key); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ReadStateFileWithKey(encryptedFn, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != testState {
+		t.Fatalf("expected %s, got %s", testState, data)
+	}
+
+	// an encrypted state file without a key or with the wrong key is an
+	// error so that the browser does not start with an empty session
+	if _, err := ReadStateFileWithKey(encryptedFn, nil); !errors.Is(err, ErrNoStateKey) {
+		t.Fatalf("expected ErrNoStateKey, got %v", err)
+	}
+	if _, err := ReadStateFileWithKey(encryptedFn, newTestStateKey(t)); err == nil {
+		t.Fatal("expected reading with the wrong key to fail")
+	}
+
+	if _, err := ReadStateFileWithKey(filepath.Join(dir, "missing.json"), key); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected os.ErrNotExist for a missing state file, got %v", err)
+	}
+}
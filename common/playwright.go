@@ -16,6 +16,7 @@ package common
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
 	"strings"
 
@@ -25,10 +26,11 @@ import (
 )
 
 // StealthPage creates a new playwright page with stealth js loaded to prevent bot detection
-func StealthPage(context *playwright.BrowserContext) playwright.Page {
+func StealthPage(context *playwright.BrowserContext) (playwright.Page, error) {
 	page, err := (*context).NewPage()
 	if err != nil {
 		log.Error().Err(err).Msg("could not create page")
+		return nil, err
 	}
 
 	if err = page.AddInitScript(playwright.Script{
@@ -39,20 +41,22 @@ func StealthPage(context *playwright.BrowserContext) playwright.Page {
 
 	page.SetViewportSize(1920, 1080)
 
-	return page
+	return page, nil
 }
 
 // BuildUserAgent dynamically determines the user agent and removes the headless identifier
-func BuildUserAgent(browser *playwright.Browser) string {
+func BuildUserAgent(browser *playwright.Browser) (string, error) {
 	context, err := (*browser).NewContext()
 	if err != nil {
 		log.Error().Err(err).Msg("could not create context for building user agent")
+		return "", err
 	}
 	defer context.Close()
 
 	page, err := context.NewPage()
 	if err != nil {
 		log.Error().Err(err).Msg("could not create page BuildUserAgent")
+		return "", err
 	}
 
 	resp, err := page.Goto("https://playwright.dev", playwright.PageGotoOptions{
@@ -60,28 +64,32 @@ func BuildUserAgent(browser *playwright.Browser) string {
 	})
 	if err != nil {
 		log.Error().Err(err).Str("Url", "https://playwright.dev").Msg("could not load page")
+		return "", err
 	}
 
 	headers, err := resp.Request().AllHeaders()
 	if err != nil {
 		log.Error().Err(err).Msg("could not load request headers")
+		return "", err
 	}
 
 	userAgent := headers["user-agent"]
 	log.Info().Str("userAgent", userAgent).Msg("User-Agent discoverd from headers")
 	userAgent = strings.Replace(userAgent, "Headless", "", -1)
-	return userAgent
+	return userAgent, nil
 }
 
-// StartPlaywright starts the playwright server and browser, it then creates a new context and page with the stealth extensions loaded
-func StartPlaywright(headless bool) (page playwright.Page, context playwright.BrowserContext, browser playwright.Browser, pw *playwright.Playwright) {
-	pw, err := playwright.Run()
+// StartPlaywright starts the playwright server and browser, it then creates a new context and page with the stealth extensions loaded.
+// The context is loaded with the session in stateFile and the browser uses proxy unless it is empty. Anything started before
+// an error is stopped again.
+func StartPlaywright(headless bool, stateFile string, proxy string) (page playwright.Page, context playwright.BrowserContext, browser playwright.Browser, pw *playwright.Playwright, err error) {
+	pw, err = playwright.Run()
 	if err != nil {
 		log.Error().Err(err).Msg("could not launch playwright")
+		return nil, nil, nil, nil, err
 	}
 
 	var browserOpts playwright.BrowserTypeLaunchOptions
-	proxy := viper.GetString("playwright.proxy")
 	if proxy == "" {
 		log.Info().Msg("no proxy server used")
 		browserOpts = playwright.BrowserTypeLaunchOptions{
@@ -99,7 +107,11 @@ func StartPlaywright(headless bool) (page playwright.Page, context playwright.Br
 
 	browser, err = pw.Chromium.Launch(browserOpts)
 	if err != nil {
-		log.Fatal().Err(err).Str("exe", pw.Chromium.ExecutablePath()).Msg("could not launch Chromium")
+		log.Error().Err(err).Str("exe", pw.Chromium.ExecutablePath()).Msg("could not launch Chromium")
+		if stopErr := pw.Stop(); stopErr != nil {
+			log.Error().Err(stopErr).Msg("error encountered when stopping playwright")
+		}
+		return nil, nil, nil, nil, err
 	}
 
 	log.Info().Bool("Headless", headless).Str("ExecutablePath", pw.Chromium.ExecutablePath()).Str("BrowserVersion", browser.Version()).Msg("starting playwright")
@@ -107,21 +119,31 @@ func StartPlaywright(headless bool) (page playwright.Page, context playwright.Br
 	// calculate user-agent
 	userAgent := viper.GetString("playwright.user_agent")
 	if userAgent == "" {
-		userAgent = BuildUserAgent(&browser)
+		if userAgent, err = BuildUserAgent(&browser); err != nil {
+			ClosePlaywright(browser, pw)
+			return nil, nil, nil, nil, err
+		}
 	}
 	log.Info().Str("UserAgent", userAgent).Msg("using user-agent")
 
-	// load browser state
-	stateFileName := viper.GetString("playwright.state_file")
-	log.Info().Str("StateFile", stateFileName).Msg("state location")
+	// load browser state; only a missing state file starts an empty session,
+	// otherwise saving the session on stop would overwrite the state file
+	log.Info().Str("StateFile", stateFile).Msg("state location")
 	var storageState playwright.OptionalStorageState
-	data, err := os.ReadFile(stateFileName)
-	if err != nil {
-		log.Error().Err(err)
-	}
-	err = json.Unmarshal(data, &storageState)
-	if err != nil {
-		log.Error().Err(err)
+	data, err := ReadStateFile(stateFile)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		log.Warn().Str("StateFile", stateFile).Msg("state file does not exist; starting with an empty session")
+	case err != nil:
+		log.Error().Err(err).Str("StateFile", stateFile).Msg("could not read state file")
+		ClosePlaywright(browser, pw)
+		return nil, nil, nil, nil, err
+	default:
+		if err = json.Unmarshal(data, &storageState); err != nil {
+			log.Error().Err(err).Str("StateFile", stateFile).Msg("could not parse state file")
+			ClosePlaywright(browser, pw)
+			return nil, nil, nil, nil, err
+		}
 	}
 
 	// create context
@@ -130,25 +152,56 @@ func StartPlaywright(headless bool) (page playwright.Page, context playwright.Br
 		StorageState: &storageState,
 	})
 	if err != nil {
-		log.Error().Msg("could not create browser context")
+		log.Error().Err(err).Msg("could not create browser context")
+		ClosePlaywright(browser, pw)
+		return nil, nil, nil, nil, err
 	}
 
 	// get a page
-	page = StealthPage(&context)
+	page, err = StealthPage(&context)
+	if err != nil {
+		ClosePlaywright(browser, pw)
+		return nil, nil, nil, nil, err
+	}
 
-	return
+	return page, context, browser, pw, nil
 }
 
-func StopPlaywright(page playwright.Page, context playwright.BrowserContext, browser playwright.Browser, pw *playwright.Playwright) {
-	// save session state
+// SavePlaywrightState saves the session state of context, along with the user
+// agent of page, to stateFile
+func SavePlaywrightState(page playwright.Page, context playwright.BrowserContext, stateFile string) error {
 	log.Info().Msg("saving state")
-	stateFileName := viper.GetString("playwright.state_file")
-	storage, err := context.StorageState(stateFileName)
+	storage, err := context.StorageState()
 	if err != nil {
 		log.Error().Err(err).Msg("could not get storage state")
+		return err
+	}
+
+	state := &SessionState{StorageState: *storage}
+	if userAgent, err := page.Evaluate("() => navigator.userAgent"); err != nil {
+		log.Warn().Err(err).Msg("could not read user agent")
+	} else {
+		state.UserAgent, _ = userAgent.(string)
+	}
+
+	if err := WriteSessionState(stateFile, state); err != nil {
+		log.Error().Err(err).Str("StateFile", stateFile).Msg("could not save storage state")
+		return err
 	}
 	log.Info().Int("NumCookies", len(storage.Cookies)).Msg("session state")
 
+	return nil
+}
+
+// StopPlaywright saves the session state to stateFile and stops the browser and playwright server
+func StopPlaywright(page playwright.Page, context playwright.BrowserContext, browser playwright.Browser, pw *playwright.Playwright, stateFile string) {
+	SavePlaywrightState(page, context, stateFile)
+	ClosePlaywright(browser, pw)
+}
+
+// ClosePlaywright stops the browser and playwright server without saving the
+// session state
+func ClosePlaywright(browser playwright.Browser, pw *playwright.Playwright) {
 	log.Info().Msg("closing browser")
 	if err := browser.Close(); err != nil {
 		log.Error().Err(err).Msg("error encountered when closing browser")
@@ -0,0 +1,132 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// SessionState is the playwright storage state saved in the state file along
+// with the user agent of the browser that created it. Seeking Alpha ties its
+// session cookies to the user agent, so clients that reuse the cookies must
+// send the same one.
+type SessionState struct {
+	playwright.StorageState
+	UserAgent string `json:"userAgent,omitempty"`
+}
+
+// ReadSessionState reads the state file fn, decrypting it with the configured
+// state key if it is encrypted
+func ReadSessionState(fn string) (*SessionState, error) {
+	data, err := ReadStateFile(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &SessionState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// WriteSessionState saves state to the state file fn; it is encrypted when a
+// state key is configured
+func WriteSessionState(fn string, state *SessionState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return WriteStateFile(fn, data)
+}
+
+// ReadStateFile returns the contents of the state file fn. Encrypted files are
+// decrypted with the configured state key; plaintext files are returned as is
+// so that they can be migrated.
+func ReadStateFile(fn string) ([]byte, error) {
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsEncryptedState(data) {
+		return data, nil
+	}
+
+	key, err := StateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeState(data, key)
+}
+
+// ReadStateFileWithKey returns the contents of the state file fn, decrypting
+// it with key if it is encrypted
+func ReadStateFileWithKey(fn string, key []byte) ([]byte, error) {
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeState(data, key)
+}
+
+func decodeState(data []byte, key []byte) ([]byte, error) {
+	if !IsEncryptedState(data) {
+		return data, nil
+	}
+
+	if key == nil {
+		return nil, ErrNoStateKey
+	}
+
+	return DecryptState(key, data)
+}
+
+// WriteStateFile saves data to the state file fn, encrypted with the
+// configured state key if there is one
+func WriteStateFile(fn string, data []byte) error {
+	key, err := StateKey()
+	if err != nil {
+		return err
+	}
+
+	return WriteStateFileWithKey(fn, data, key)
+}
+
+// WriteStateFileWithKey saves data to the state file fn, encrypted with key
+// unless it is nil
+func WriteStateFileWithKey(fn string, data []byte, key []byte) error {
+	if key != nil {
+		var err error
+		if data, err = EncryptState(key, data); err != nil {
+			return err
+		}
+	}
+
+	// write to a temporary file first so a crash never leaves a truncated state file
+	tmpFn := fn + ".tmp"
+	if err := os.WriteFile(tmpFn, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFn, fn)
+}
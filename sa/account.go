@@ -0,0 +1,177 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const DEFAULT_ACCOUNT string = "default"
+
+// Account rotation modes
+const (
+	// ROTATION_PAGE spreads screener pages across the accounts round-robin
+	ROTATION_PAGE string = "page"
+
+	// ROTATION_FAILOVER uses the first account until it is blocked
+	ROTATION_FAILOVER string = "failover"
+)
+
+// Account is a Seeking Alpha login with its own browser state file and
+// proxy. Accounts are configured as [[seekingalpha.accounts]] tables; without
+// them the single account in seekingalpha.username, playwright.state_file and
+// playwright.proxy is used.
+type Account struct {
+	Name      string `mapstructure:"name"`
+	StateFile string `mapstructure:"state_file"`
+	Proxy     string `mapstructure:"proxy"`
+	Username  string `mapstructure:"username"`
+	Password  string `mapstructure:"password"`
+}
+
+// DefaultAccount returns the account configured outside of
+// seekingalpha.accounts
+func DefaultAccount() *Account {
+	return &Account{
+		Name:      DEFAULT_ACCOUNT,
+		StateFile: viper.GetString("playwright.state_file"),
+		Proxy:     viper.GetString("playwright.proxy"),
+		Username:  viper.GetString("seekingalpha.username"),
+		Password:  viper.GetString("seekingalpha.password"),
+	}
+}
+
+// LoadAccounts reads the accounts from the seekingalpha.accounts
+// configuration value, or returns the default account if none are configured
+func LoadAccounts() ([]*Account, error) {
+	accounts := make([]*Account, 0)
+	if err := viper.UnmarshalKey("seekingalpha.accounts", &accounts); err != nil {
+		log.Error().Err(err).Msg("could not parse seeking alpha accounts")
+		return nil, err
+	}
+
+	if len(accounts) == 0 {
+		return []*Account{DefaultAccount()}, nil
+	}
+
+	names := make(map[string]bool)
+	stateFiles := make(map[string]string)
+	for _, account := range accounts {
+		if account.Name == "" {
+			return nil, fmt.Errorf("seeking alpha account is missing a name")
+		}
+
+		if names[account.Name] {
+			return nil, fmt.Errorf("seeking alpha account %s is defined more than once", account.Name)
+		}
+		names[account.Name] = true
+
+		if account.StateFile == "" {
+			account.StateFile = fmt.Sprintf("state-%s.json", account.Name)
+		}
+
+		if other, ok := stateFiles[account.StateFile]; ok {
+			return nil, fmt.Errorf("seeking alpha accounts %s and %s have the same state file %s", other, account.Name, account.StateFile)
+		}
+		stateFiles[account.StateFile] = account.Name
+	}
+
+	return accounts, nil
+}
+
+// SelectAccount returns the named account, or the first account if name is
+// empty
+func SelectAccount(name string) (*Account, error) {
+	accounts, err := LoadAccounts()
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		return accounts[0], nil
+	}
+
+	for _, account := range accounts {
+		if account.Name == name {
+			return account, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown seeking alpha account %s", name)
+}
+
+// HasCredentials returns true if the account has a username and password
+func (account *Account) HasCredentials() bool {
+	return account.Username != "" && account.Password != ""
+}
+
+func (account *Account) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("Name", account.Name)
+	e.Str("StateFile", account.StateFile)
+	if account.Proxy != "" {
+		e.Str("Proxy", account.Proxy)
+	}
+}
+
+// AccountUsage counts the requests made with an account
+type AccountUsage struct {
+	Requests int64     `json:"requests"`
+	Errors   int64     `json:"errors"`
+	Blocked  int64     `json:"blocked"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+// ReadAccountUsage reads the accumulated request counts of each account from
+// fn; a missing file has no usage
+func ReadAccountUsage(fn string) (map[string]*AccountUsage, error) {
+	usage := make(map[string]*AccountUsage)
+
+	data, err := os.ReadFile(fn)
+	if errors.Is(err, os.ErrNotExist) {
+		return usage, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &usage); err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+// WriteAccountUsage saves the accumulated request counts of each account to fn
+func WriteAccountUsage(fn string, usage map[string]*AccountUsage) error {
+	data, err := json.MarshalIndent(usage, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpFn := fn + ".tmp"
+	if err := os.WriteFile(tmpFn, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFn, fn)
+}
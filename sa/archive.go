@@ -0,0 +1,260 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/penny-vault/import-sa-quant-rank/common"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	ARCHIVE_MANIFEST_NAME     string = "manifest.json"
+	ARCHIVE_SCREENER_ENDPOINT string = "screener"
+)
+
+// ArchiveEntry describes a single raw response stored in the archive
+type ArchiveEntry struct {
+	Name      string    `json:"name"`
+	Page      int       `json:"page"`
+	Endpoint  string    `json:"endpoint"`
+	Attempt   int       `json:"attempt"`
+	Url       string    `json:"url"`
+	Status    int       `json:"status"`
+	Size      int       `json:"size"`
+	FetchedAt time.Time `json:"fetchedAt"`
+}
+
+// ArchiveManifest lists every response in the archive in the order they were
+// received
+type ArchiveManifest struct {
+	Date    string          `json:"date"`
+	Profile string          `json:"profile"`
+	Version string          `json:"version"`
+	Created time.Time       `json:"created"`
+	Entries []*ArchiveEntry `json:"entries"`
+}
+
+// Archive writes every raw Seeking Alpha response of a run into a gzip
+// compressed tar file. Entries are named <date>/page-<page>/<endpoint>.json;
+// retried requests are stored as <endpoint>-<attempt>.json. A manifest.json is
+// written when the archive is closed. The archive is written to a temporary
+// file that replaces FileName on Close so an interrupted run never leaves a
+// truncated archive behind.
+type Archive struct {
+	FileName string
+
+	fh       *os.File
+	gz       *gzip.Writer
+	tw       *tar.Writer
+	manifest ArchiveManifest
+	attempts map[string]int
+	mu       sync.Mutex
+}
+
+// NewArchive creates a raw response archive at fn for the given market date
+// and screener profile
+func NewArchive(fn string, date time.Time, profile string) (*Archive, error) {
+	fh, err := os.Create(fn + ".tmp")
+	if err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("could not create raw response archive")
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(fh)
+	archive := &Archive{
+		FileName: fn,
+		fh:       fh,
+		gz:       gz,
+		tw:       tar.NewWriter(gz),
+		manifest: ArchiveManifest{
+			Date:    date.Format("20060102"),
+			Profile: profile,
+			Version: common.CurrentVersion.String(),
+			Created: time.Now(),
+			Entries: make([]*ArchiveEntry, 0),
+		},
+		attempts: make(map[string]int),
+	}
+
+	return archive, nil
+}
+
+// OpenArchive opens the raw response archive at fn so a resumed run appends to
+// it. The responses of the existing archive are carried over and retried pages
+// continue its attempt numbering. A missing archive, or one of a different
+// market date, is replaced by an empty archive.
+func OpenArchive(fn string, date time.Time, profile string) (*Archive, error) {
+	if _, err := os.Stat(fn); errors.Is(err, os.ErrNotExist) {
+		return NewArchive(fn, date, profile)
+	}
+
+	manifest, contents, err := ReadArchive(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := NewArchive(fn, date, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	if manifest.Date != archive.manifest.Date {
+		log.Warn().Str("FileName", fn).Str("ArchiveDate", manifest.Date).Str("Date", archive.manifest.Date).Msg("raw response archive is for a different date; starting a new archive")
+		return archive, nil
+	}
+
+	for _, entry := range manifest.Entries {
+		body, ok := contents[entry.Name]
+		if !ok {
+			archive.discard()
+			return nil, fmt.Errorf("archive entry %s is missing", entry.Name)
+		}
+
+		if err := archive.write(entry.Name, body); err != nil {
+			archive.discard()
+			return nil, err
+		}
+
+		archive.attempts[archiveKey(manifest.Date, entry.Page, entry.Endpoint)] = entry.Attempt
+		archive.manifest.Entries = append(archive.manifest.Entries, entry)
+	}
+	archive.manifest.Created = manifest.Created
+
+	log.Info().Str("FileName", fn).Int("NumEntries", len(manifest.Entries)).Msg("appending to raw response archive")
+	return archive, nil
+}
+
+// archiveKey names the responses of an endpoint on a screener page
+func archiveKey(date string, pageNum int, endpoint string) string {
+	return fmt.Sprintf("%s/page-%04d/%s", date, pageNum, endpoint)
+}
+
+// Add stores the raw body of a response. It is safe to call Add on a nil
+// archive, in which case the response is discarded.
+func (archive *Archive) Add(pageNum int, endpoint, url string, status int, body []byte) error {
+	if archive == nil {
+		return nil
+	}
+
+	archive.mu.Lock()
+	defer archive.mu.Unlock()
+
+	name := archiveKey(archive.manifest.Date, pageNum, endpoint)
+	archive.attempts[name]++
+	attempt := archive.attempts[name]
+	if attempt > 1 {
+		name = fmt.Sprintf("%s-%d", name, attempt)
+	}
+
+	entry := &ArchiveEntry{
+		Name:      name + ".json",
+		Page:      pageNum,
+		Endpoint:  endpoint,
+		Attempt:   attempt,
+		Url:       url,
+		Status:    status,
+		Size:      len(body),
+		FetchedAt: time.Now(),
+	}
+
+	if err := archive.write(entry.Name, body); err != nil {
+		log.Error().Err(err).Str("Entry", entry.Name).Msg("could not write response to archive")
+		return err
+	}
+
+	archive.manifest.Entries = append(archive.manifest.Entries, entry)
+	return nil
+}
+
+// Close writes the manifest and flushes the archive to disk
+func (archive *Archive) Close() error {
+	if archive == nil {
+		return nil
+	}
+
+	archive.mu.Lock()
+	defer archive.mu.Unlock()
+
+	manifest, err := json.MarshalIndent(archive.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := archive.write(ARCHIVE_MANIFEST_NAME, manifest); err != nil {
+		return err
+	}
+
+	if err := archive.tw.Close(); err != nil {
+		return err
+	}
+
+	if err := archive.gz.Close(); err != nil {
+		return err
+	}
+
+	if err := archive.fh.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(archive.fh.Name(), archive.FileName); err != nil {
+		return err
+	}
+
+	log.Info().Str("FileName", archive.FileName).Int("NumEntries", len(archive.manifest.Entries)).Msg("raw response archive written")
+	return nil
+}
+
+// Remove deletes the archive file once it is no longer needed
+func (archive *Archive) Remove() {
+	if archive == nil {
+		return
+	}
+
+	if err := os.Remove(archive.FileName); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warn().Err(err).Str("FileName", archive.FileName).Msg("could not remove raw response archive")
+	}
+}
+
+// discard closes and deletes the temporary file of an archive that could not
+// be opened
+func (archive *Archive) discard() {
+	archive.fh.Close()
+	os.Remove(archive.fh.Name())
+}
+
+func (archive *Archive) write(name string, body []byte) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(body)),
+		ModTime: time.Now(),
+	}
+
+	if err := archive.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	_, err := archive.tw.Write(body)
+	return err
+}
@@ -0,0 +1,191 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+//go:embed nyse_calendar.toml
+var defaultCalendar []byte
+
+// Policies for runs on days the NYSE is closed
+const (
+	NON_TRADING_DAY_SKIP     string = "skip"
+	NON_TRADING_DAY_PREVIOUS string = "previous"
+	NON_TRADING_DAY_NEXT     string = "next"
+)
+
+// ErrNonTradingDay is returned when the NYSE is closed on the requested date
+// and the non-trading day policy is skip
+var ErrNonTradingDay = errors.New("not an NYSE trading day")
+
+// Holiday is a weekday on which the NYSE is closed
+type Holiday struct {
+	Date string `mapstructure:"date"`
+	Name string `mapstructure:"name"`
+}
+
+// Calendar lists the days the NYSE is closed
+type Calendar struct {
+	Years    []int      `mapstructure:"years"`
+	Holidays []*Holiday `mapstructure:"holiday"`
+
+	closed map[string]string
+	years  map[int]bool
+}
+
+// LoadCalendar reads the embedded NYSE calendar and adds the holidays and
+// years in the file named by the calendar.file configuration value
+func LoadCalendar() (*Calendar, error) {
+	calendar, err := readCalendar(defaultCalendar)
+	if err != nil {
+		return nil, fmt.Errorf("embedded trading calendar: %w", err)
+	}
+
+	if fn := viper.GetString("calendar.file"); fn != "" {
+		log.Info().Str("FileName", fn).Msg("loading trading calendar additions")
+		data, err := os.ReadFile(fn)
+		if err != nil {
+			return nil, err
+		}
+
+		additions, err := readCalendar(data)
+		if err != nil {
+			return nil, fmt.Errorf("trading calendar %s: %w", fn, err)
+		}
+
+		calendar.Years = append(calendar.Years, additions.Years...)
+		calendar.Holidays = append(calendar.Holidays, additions.Holidays...)
+	}
+
+	calendar.closed = make(map[string]string, len(calendar.Holidays))
+	for _, holiday := range calendar.Holidays {
+		if _, err := time.Parse("2006-01-02", holiday.Date); err != nil {
+			return nil, fmt.Errorf("holiday %s has invalid date %s", holiday.Name, holiday.Date)
+		}
+		calendar.closed[holiday.Date] = holiday.Name
+	}
+
+	calendar.years = make(map[int]bool, len(calendar.Years))
+	for _, year := range calendar.Years {
+		calendar.years[year] = true
+	}
+
+	return calendar, nil
+}
+
+func readCalendar(data []byte) (*Calendar, error) {
+	v := viper.New()
+	v.SetConfigType("toml")
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	calendar := &Calendar{}
+	if err := v.Unmarshal(calendar); err != nil {
+		return nil, err
+	}
+
+	return calendar, nil
+}
+
+// IsTradingDay returns true if the NYSE is open on the day of dt
+func (calendar *Calendar) IsTradingDay(dt time.Time) bool {
+	if dt.Weekday() == time.Saturday || dt.Weekday() == time.Sunday {
+		return false
+	}
+
+	_, closed := calendar.closed[dt.Format("2006-01-02")]
+	return !closed
+}
+
+// Session returns the market open of the trading session that a run on the
+// day of dt is stamped with according to policy
+func (calendar *Calendar) Session(dt time.Time, policy string) (time.Time, error) {
+	if !calendar.years[dt.Year()] {
+		log.Warn().Int("Year", dt.Year()).Msg("trading calendar has no holidays for year; only weekends are treated as non-trading days")
+	}
+
+	if calendar.IsTradingDay(dt) {
+		return marketOpen(dt), nil
+	}
+
+	reason := "weekend"
+	if name, ok := calendar.closed[dt.Format("2006-01-02")]; ok {
+		reason = name
+	}
+
+	step := 0
+	switch policy {
+	case "", NON_TRADING_DAY_SKIP:
+		log.Warn().Str("Date", dt.Format("2006-01-02")).Str("Reason", reason).Msg("NYSE is closed")
+		return time.Time{}, ErrNonTradingDay
+	case NON_TRADING_DAY_PREVIOUS:
+		step = -1
+	case NON_TRADING_DAY_NEXT:
+		step = 1
+	default:
+		return time.Time{}, fmt.Errorf("unknown non-trading day policy %s", policy)
+	}
+
+	session := dt
+	for !calendar.IsTradingDay(session) {
+		session = session.AddDate(0, 0, step)
+	}
+
+	log.Info().Str("Date", dt.Format("2006-01-02")).Str("Reason", reason).Str("Session", session.Format("2006-01-02")).Msg("NYSE is closed; using adjacent trading session")
+	return marketOpen(session), nil
+}
+
+// TradingSession returns the market open of the session that a run on the
+// day of dt belongs to using the NYSE calendar and the calendar.non_trading_day
+// policy
+func TradingSession(dt time.Time) (time.Time, error) {
+	calendar, err := LoadCalendar()
+	if err != nil {
+		log.Error().Err(err).Msg("could not load trading calendar")
+		return time.Time{}, err
+	}
+
+	return calendar.Session(dt, viper.GetString("calendar.non_trading_day"))
+}
+
+// MarketTime returns the market open of the trading session for the current
+// day in New York
+func MarketTime() (time.Time, error) {
+	return TradingSession(time.Now().In(newYork()))
+}
+
+// marketOpen returns the market open in New York on the day of dt
+func marketOpen(dt time.Time) time.Time {
+	return time.Date(dt.Year(), dt.Month(), dt.Day(), 9, 30, 0, 0, newYork())
+}
+
+func newYork() *time.Location {
+	nyc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		log.Error().Err(err).Msg("could not load timezone")
+	}
+	return nyc
+}
@@ -0,0 +1,305 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// metrics.toml declares every metric requested from Seeking Alpha and the
+// record column it is stored in. It can be extended or overridden with the
+// file named by the catalog.file configuration value.
+
+//go:embed metrics.toml
+var defaultCatalog []byte
+
+// MetricGroup is a single request to the Seeking Alpha API that fetches all
+// metrics assigned to the group
+type MetricGroup struct {
+	Name     string `mapstructure:"name"`
+	Endpoint string `mapstructure:"endpoint"`
+	Params   string `mapstructure:"params"`
+}
+
+// MetricDef describes how a Seeking Alpha field is parsed into a record
+type MetricDef struct {
+	Field      string `mapstructure:"field"`
+	Column     string `mapstructure:"column"`
+	Attribute  string `mapstructure:"attribute"`
+	Type       string `mapstructure:"type"`
+	Meaningful bool   `mapstructure:"meaningful"`
+	Group      string `mapstructure:"group"`
+
+	// FallbackAttribute is read when the item has no Attribute
+	FallbackAttribute string `mapstructure:"fallback_attribute"`
+
+	fieldIndex []int
+}
+
+// Catalog is the set of metrics requested from Seeking Alpha
+type Catalog struct {
+	Groups  []*MetricGroup `mapstructure:"group"`
+	Metrics []*MetricDef   `mapstructure:"metric"`
+
+	byField    map[string]*MetricDef
+	recordType reflect.Type
+}
+
+// columnTypes maps catalog types onto the type of the record column they fill
+var columnTypes = map[string]reflect.Type{
+	"float32":   reflect.TypeOf((*float32)(nil)),
+	"float64":   reflect.TypeOf((*float64)(nil)),
+	"int64":     reflect.TypeOf((*int64)(nil)),
+	"timestamp": reflect.TypeOf((*int64)(nil)),
+}
+
+// LoadCatalog reads the embedded metric catalog of record type R and merges in
+// its override file; catalog.file for stocks and catalog.etf_file for ETFs.
+// Groups and metrics in the override file replace embedded entries with the
+// same name or field.
+func LoadCatalog[R Record]() (*Catalog, error) {
+	var record R
+	embedded, overrideKey := record.catalog()
+
+	catalog, err := readCatalog(bytes.NewReader(embedded))
+	if err != nil {
+		return nil, fmt.Errorf("embedded metric catalog: %w", err)
+	}
+	catalog.recordType = reflect.TypeOf(record).Elem()
+
+	if fn := viper.GetString(overrideKey); fn != "" {
+		log.Info().Str("FileName", fn).Msg("loading metric catalog overrides")
+		data, err := os.ReadFile(fn)
+		if err != nil {
+			return nil, err
+		}
+
+		override, err := readCatalog(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("metric catalog %s: %w", fn, err)
+		}
+
+		catalog.merge(override)
+	}
+
+	if err := catalog.validate(); err != nil {
+		return nil, err
+	}
+
+	return catalog, nil
+}
+
+func readCatalog(data *bytes.Reader) (*Catalog, error) {
+	v := viper.New()
+	v.SetConfigType("toml")
+	if err := v.ReadConfig(data); err != nil {
+		return nil, err
+	}
+
+	catalog := &Catalog{}
+	if err := v.Unmarshal(catalog); err != nil {
+		return nil, err
+	}
+
+	return catalog, nil
+}
+
+func (catalog *Catalog) merge(override *Catalog) {
+	for _, group := range override.Groups {
+		replaced := false
+		for idx, existing := range catalog.Groups {
+			if existing.Name == group.Name {
+				catalog.Groups[idx] = group
+				replaced = true
+			}
+		}
+		if !replaced {
+			catalog.Groups = append(catalog.Groups, group)
+		}
+	}
+
+	for _, metric := range override.Metrics {
+		replaced := false
+		for idx, existing := range catalog.Metrics {
+			if existing.Field == metric.Field {
+				catalog.Metrics[idx] = metric
+				replaced = true
+			}
+		}
+		if !replaced {
+			catalog.Metrics = append(catalog.Metrics, metric)
+		}
+	}
+}
+
+// validate checks that every metric maps onto a record column of the right
+// type and that every metric column of the record is filled by the catalog
+func (catalog *Catalog) validate() error {
+	recordType := catalog.recordType
+	groups := make(map[string]bool, len(catalog.Groups))
+	for _, group := range catalog.Groups {
+		groups[group.Name] = true
+	}
+
+	catalog.byField = make(map[string]*MetricDef, len(catalog.Metrics))
+	columns := make(map[string]bool)
+
+	for _, metric := range catalog.Metrics {
+		if _, ok := catalog.byField[metric.Field]; ok {
+			return fmt.Errorf("metric %s is declared more than once", metric.Field)
+		}
+
+		if !groups[metric.Group] {
+			return fmt.Errorf("metric %s references unknown group %s", metric.Field, metric.Group)
+		}
+
+		if !isAttribute(metric.Attribute) {
+			return fmt.Errorf("metric %s has unknown attribute %s", metric.Field, metric.Attribute)
+		}
+
+		if metric.FallbackAttribute != "" && (!isAttribute(metric.FallbackAttribute) || metric.FallbackAttribute == metric.Attribute) {
+			return fmt.Errorf("metric %s has invalid fallback attribute %s", metric.Field, metric.FallbackAttribute)
+		}
+
+		expectedType, ok := columnTypes[metric.Type]
+		if !ok {
+			return fmt.Errorf("metric %s has unknown type %s", metric.Field, metric.Type)
+		}
+
+		if metric.Column != "" {
+			field, ok := recordType.FieldByName(metric.Column)
+			if !ok {
+				return fmt.Errorf("metric %s references unknown column %s", metric.Field, metric.Column)
+			}
+			if field.Type != expectedType {
+				return fmt.Errorf("metric %s has type %s but column %s is %s", metric.Field, metric.Type, metric.Column, field.Type)
+			}
+			if columns[metric.Column] {
+				return fmt.Errorf("column %s is filled by more than one metric", metric.Column)
+			}
+			metric.fieldIndex = field.Index
+			columns[metric.Column] = true
+		}
+
+		catalog.byField[metric.Field] = metric
+	}
+
+	// every nullable column on the record is a metric column
+	missing := make([]string, 0)
+	for ii := 0; ii < recordType.NumField(); ii++ {
+		field := recordType.Field(ii)
+		if field.Type.Kind() == reflect.Pointer && !columns[field.Name] {
+			missing = append(missing, field.Name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("no metric in catalog for declared columns: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// isAttribute returns true if name is an item attribute that holds a metric
+func isAttribute(name string) bool {
+	return name == "value" || name == "grade"
+}
+
+// MetricRequest is the URL used to fetch the metrics of a group; the comma
+// separated list of ticker slugs must be appended to the URL
+type MetricRequest struct {
+	Group string
+	Url   string
+}
+
+// Requests returns the request for each metric group that has metrics
+func (catalog *Catalog) Requests() []*MetricRequest {
+	requests := make([]*MetricRequest, 0, len(catalog.Groups))
+	for _, group := range catalog.Groups {
+		fields := make([]string, 0)
+		for _, metric := range catalog.Metrics {
+			if metric.Group == group.Name {
+				fields = append(fields, metric.Field)
+			}
+		}
+
+		if len(fields) == 0 {
+			continue
+		}
+
+		params := ""
+		if group.Params != "" {
+			params = group.Params + "&"
+		}
+
+		requests = append(requests, &MetricRequest{
+			Group: group.Name,
+			Url:   fmt.Sprintf("%s/%s?%sfilter[fields]=%s&filter[slugs]=", API_BASE_URL, group.Endpoint, params, strings.Join(fields, "%2C")),
+		})
+	}
+
+	return requests
+}
+
+// Metric returns the definition of the named Seeking Alpha field
+func (catalog *Catalog) Metric(field string) (*MetricDef, bool) {
+	metric, ok := catalog.byField[field]
+	return metric, ok
+}
+
+// attribute returns the name and value of the item attribute holding the
+// metric, falling back to FallbackAttribute when Attribute is missing
+func (metric *MetricDef) attribute(item MetricItem) (string, any) {
+	val := item.Attributes[metric.Attribute]
+	if val == nil && metric.FallbackAttribute != "" {
+		return metric.FallbackAttribute, item.Attributes[metric.FallbackAttribute]
+	}
+	return metric.Attribute, val
+}
+
+// set stores val in the record column of the metric or in Extras when the
+// metric has no column
+func (metric *MetricDef) set(record Record, val float64) {
+	if metric.fieldIndex == nil {
+		record.setExtra(metric.Field, val)
+		return
+	}
+
+	column := reflect.ValueOf(record).Elem().FieldByIndex(metric.fieldIndex)
+	switch metric.Type {
+	case "float32":
+		column.Set(reflect.ValueOf(ptr(float32(val))))
+	case "float64":
+		column.Set(reflect.ValueOf(ptr(val)))
+	case "int64", "timestamp":
+		column.Set(reflect.ValueOf(ptr(int64(val))))
+	}
+}
+
+// isSet returns true if the record holds a value for the metric
+func (metric *MetricDef) isSet(record Record) bool {
+	if metric.fieldIndex == nil {
+		return record.hasExtra(metric.Field)
+	}
+	return !reflect.ValueOf(record).Elem().FieldByIndex(metric.fieldIndex).IsNil()
+}
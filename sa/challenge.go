@@ -0,0 +1,63 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrChallengeUnsolved is returned when a PerimeterX block or challenge could
+// not be cleared
+var ErrChallengeUnsolved = errors.New("perimeterx challenge could not be solved")
+
+// ChallengeSolver is implemented by transports that can clear a PerimeterX
+// challenge, e.g. by pressing and holding the human challenge button
+type ChallengeSolver interface {
+	SolveChallenge() error
+}
+
+// isChallenge returns true if a response is a PerimeterX block or challenge
+// rather than API data: a body with PerimeterX markup, or an HTML body that
+// was returned with status 200 or along with PerimeterX cookies
+func isChallenge(status int, header http.Header, body []byte) bool {
+	if isBlockPage(body) {
+		return true
+	}
+
+	if !isHtml(body) {
+		return false
+	}
+
+	return status == http.StatusOK || setsPxCookie(header)
+}
+
+// isHtml returns true if body is an HTML document; the API only returns JSON
+func isHtml(body []byte) bool {
+	trimmed := bytes.TrimSpace(body)
+	return bytes.HasPrefix(trimmed, []byte("<"))
+}
+
+// setsPxCookie returns true if the response sets a PerimeterX cookie
+func setsPxCookie(header http.Header) bool {
+	for _, cookie := range header.Values("Set-Cookie") {
+		if strings.HasPrefix(strings.TrimSpace(cookie), "_px") {
+			return true
+		}
+	}
+	return false
+}
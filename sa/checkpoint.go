@@ -0,0 +1,112 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Checkpoint records the screener pages that have been downloaded along with
+// their parsed records so an interrupted run can be resumed
+type Checkpoint[R Record] struct {
+	Date     string `json:"date"`
+	LastPage int    `json:"lastPage"`
+	NumPages int    `json:"numPages"`
+
+	// ScreenerCount and Slugs are kept so the screener results can be
+	// reconciled against the records of a resumed run
+	ScreenerCount int      `json:"screenerCount"`
+	Slugs         []string `json:"slugs"`
+
+	Records map[string]R `json:"records"`
+
+	fn string
+}
+
+// NewCheckpoint creates an empty checkpoint stored in fn for the given market date
+func NewCheckpoint[R Record](fn string, date time.Time) *Checkpoint[R] {
+	return &Checkpoint[R]{
+		Date:    date.Format("20060102"),
+		Records: make(map[string]R),
+		fn:      fn,
+	}
+}
+
+// LoadCheckpoint reads the checkpoint stored in fn. If the file does not exist
+// or belongs to a different market date an empty checkpoint is returned.
+func LoadCheckpoint[R Record](fn string, date time.Time) (*Checkpoint[R], error) {
+	checkpoint := NewCheckpoint[R](fn, date)
+
+	data, err := os.ReadFile(fn)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Info().Str("FileName", fn).Msg("no checkpoint found; starting from the first page")
+		return checkpoint, nil
+	}
+	if err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("could not read checkpoint")
+		return nil, err
+	}
+
+	var saved Checkpoint[R]
+	if err := json.Unmarshal(data, &saved); err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("could not parse checkpoint")
+		return nil, err
+	}
+
+	if saved.Date != checkpoint.Date {
+		log.Warn().Str("FileName", fn).Str("CheckpointDate", saved.Date).Str("Date", checkpoint.Date).Msg("checkpoint is for a different date; starting from the first page")
+		return checkpoint, nil
+	}
+
+	saved.fn = fn
+	if saved.Records == nil {
+		saved.Records = make(map[string]R)
+	}
+
+	log.Info().Str("FileName", fn).Int("LastPage", saved.LastPage).Int("NumPages", saved.NumPages).Int("NumRecords", len(saved.Records)).Msg("resuming from checkpoint")
+	return &saved, nil
+}
+
+// Save marks pageNum as complete and writes the checkpoint to disk
+func (checkpoint *Checkpoint[R]) Save(pageNum, numPages int) error {
+	checkpoint.LastPage = pageNum
+	checkpoint.NumPages = numPages
+
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so a crash never leaves a truncated checkpoint
+	tmpFn := checkpoint.fn + ".tmp"
+	if err := os.WriteFile(tmpFn, data, 0600); err != nil {
+		log.Error().Err(err).Str("FileName", tmpFn).Msg("could not write checkpoint")
+		return err
+	}
+
+	return os.Rename(tmpFn, checkpoint.fn)
+}
+
+// Remove deletes the checkpoint file
+func (checkpoint *Checkpoint[R]) Remove() {
+	if err := os.Remove(checkpoint.fn); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warn().Err(err).Str("FileName", checkpoint.fn).Msg("could not remove checkpoint")
+	}
+}
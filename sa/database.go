@@ -16,7 +16,9 @@ package sa
 
 import (
 	"context"
+	_ "embed"
 	"strings"
+	"time"
 
 	"github.com/adrg/strutil"
 	"github.com/adrg/strutil/metrics"
@@ -25,7 +27,10 @@ import (
 	"github.com/spf13/viper"
 )
 
-func EnrichWithFigi(records []*SeekingAlphaRecord) []*SeekingAlphaRecord {
+// EnrichWithFigi fills in the composite FIGI of each record from the assets
+// table. Stocks and ETFs are resolved the same way: first by Seeking Alpha
+// ticker id and then by ticker and a similar company or fund name.
+func EnrichWithFigi[R Record](records []R) []R {
 	conn, err := pgx.Connect(context.Background(), viper.GetString("database.url"))
 	if err != nil {
 		log.Error().Err(err).Msg("Could not connect to database")
@@ -49,23 +54,25 @@ func EnrichWithFigi(records []*SeekingAlphaRecord) []*SeekingAlphaRecord {
 	}
 
 	// Fill out composite figi in sa records
-	missingTickers := make(map[string]*SeekingAlphaRecord)
+	missingTickers := make(map[string]R)
 	for _, r := range records {
-		if t, ok := saIdMap[r.TickerId]; ok {
-			if r.Ticker == t.Ticker {
-				r.CompositeFigi = t.CompositeFigi
+		security := r.Security()
+		if t, ok := saIdMap[security.TickerId]; ok {
+			if security.Ticker == t.Ticker {
+				r.setCompositeFigi(t.CompositeFigi)
 			} else {
-				missingTickers[r.Ticker] = r
+				missingTickers[security.Ticker] = r
 			}
 		} else {
-			missingTickers[r.Ticker] = r
+			missingTickers[security.Ticker] = r
 		}
 	}
 
 	// For each missing FIGI search for it in the assets table
 	for tickerStr, record := range missingTickers {
 		var ticker Ticker
-		saTickerId := record.TickerId
+		security := record.Security()
+		saTickerId := security.TickerId
 
 		if isValidExchange(record) {
 			log.Info().Str("Ticker", tickerStr).Int("SeekingAlphaId", saTickerId).Msg("Ticker is not currently associated with Seeking Alpha ID in database")
@@ -93,15 +100,15 @@ func EnrichWithFigi(records []*SeekingAlphaRecord) []*SeekingAlphaRecord {
 		}
 
 		// first make sure the company names are similar - as a protective measure
-		similarity := strutil.Similarity(strings.ToLower(ticker.CompanyName), strings.ToLower(record.CompanyName), metrics.NewJaroWinkler())
+		similarity := strutil.Similarity(strings.ToLower(ticker.CompanyName), strings.ToLower(security.CompanyName), metrics.NewJaroWinkler())
 		if similarity < .7 {
-			log.Warn().Float64("Similarity", similarity).Str("ticker", record.Ticker).Int("SeekingAlphaId", record.TickerId).Str("DbCompanyName", ticker.CompanyName).Str("SaCompanyName", record.CompanyName).Msg("Not linking ticker due to company name's being too dissimilar")
+			log.Warn().Float64("Similarity", similarity).Str("ticker", security.Ticker).Int("SeekingAlphaId", security.TickerId).Str("DbCompanyName", ticker.CompanyName).Str("SaCompanyName", security.CompanyName).Msg("Not linking ticker due to company name's being too dissimilar")
 			continue
 		}
 
 		ticker.TickerId = saTickerId
 		saIdMap[saTickerId] = &ticker
-		record.CompositeFigi = ticker.CompositeFigi
+		record.setCompositeFigi(ticker.CompositeFigi)
 
 		// Update database with Seeking Alpha ID
 		_, err = conn.Exec(context.Background(), `
@@ -121,6 +128,31 @@ func EnrichWithFigi(records []*SeekingAlphaRecord) []*SeekingAlphaRecord {
 	return records
 }
 
+//go:embed schema.sql
+var schemaMigration string
+
+//go:embed schema_etf.sql
+var etfSchemaMigration string
+
+// MigrateDB adds the columns written by SaveToDB to an existing seeking_alpha
+// table
+func MigrateDB(conn *pgx.Conn) error {
+	return migrate(conn, "seeking_alpha", schemaMigration)
+}
+
+// MigrateEtfDB creates the seeking_alpha_etf table written by SaveEtfsToDB
+func MigrateEtfDB(conn *pgx.Conn) error {
+	return migrate(conn, "seeking_alpha_etf", etfSchemaMigration)
+}
+
+func migrate(conn *pgx.Conn, table, migration string) error {
+	if _, err := conn.Exec(context.Background(), migration); err != nil {
+		log.Error().Err(err).Str("Table", table).Msg("could not migrate table")
+		return err
+	}
+	return nil
+}
+
 func SaveToDB(records []*SeekingAlphaRecord) {
 	conn, err := pgx.Connect(context.Background(), viper.GetString("database.url"))
 	if err != nil {
@@ -128,6 +160,12 @@ func SaveToDB(records []*SeekingAlphaRecord) {
 	}
 	defer conn.Close(context.Background())
 
+	if err := MigrateDB(conn); err != nil {
+		return
+	}
+
+	numFailed := 0
+
 	for _, r := range records {
 		if !isValidExchange(r) {
 			// not in a recognized exchange ... skip
@@ -137,7 +175,7 @@ func SaveToDB(records []*SeekingAlphaRecord) {
 			log.Warn().Object("SAQuantRecord", r).Msg("skipping due to missing CompositeFigi")
 			continue
 		}
-		conn.Exec(context.Background(),
+		_, err := conn.Exec(context.Background(),
 			`INSERT INTO seeking_alpha (
 			"ticker",
 			"composite_figi",
@@ -149,7 +187,29 @@ func SaveToDB(records []*SeekingAlphaRecord) {
 			"value_grade",
 			"eps_revisions_grade",
 			"authors_rating_pro",
-			"sell_side_rating"
+			"sell_side_rating",
+			"div_safety_grade",
+			"div_growth_grade",
+			"div_yield_grade",
+			"div_consistency_grade",
+			"last_div_date",
+			"div_pay_date",
+			"dividend_yield",
+			"div_yield_fwd",
+			"div_yield_4y",
+			"div_rate_ttm",
+			"div_rate_fwd",
+			"payout_ratio",
+			"payout_ratio_4y",
+			"div_grow_rate_3y",
+			"div_grow_rate_5y",
+			"dividend_growth",
+			"is_reit",
+			"is_bdc",
+			"is_defunct",
+			"sector",
+			"industry",
+			"earning_announce_date"
 		) VALUES (
 			$1,
 			$2,
@@ -161,7 +221,29 @@ func SaveToDB(records []*SeekingAlphaRecord) {
 			$8,
 			$9,
 			$10,
-			$11
+			$11,
+			$12,
+			$13,
+			$14,
+			$15,
+			$16,
+			$17,
+			$18,
+			$19,
+			$20,
+			$21,
+			$22,
+			$23,
+			$24,
+			$25,
+			$26,
+			$27,
+			$28,
+			$29,
+			$30,
+			$31,
+			$32,
+			$33
 		) ON CONFLICT ON CONSTRAINT seeking_alpha_pkey
 		DO UPDATE SET
 			market_cap_mil = EXCLUDED.market_cap_mil,
@@ -171,13 +253,172 @@ func SaveToDB(records []*SeekingAlphaRecord) {
 			value_grade = EXCLUDED.value_grade,
 			eps_revisions_grade = EXCLUDED.eps_revisions_grade,
 			authors_rating_pro = EXCLUDED.authors_rating_pro,
-			sell_side_rating = EXCLUDED.sell_side_rating;
+			sell_side_rating = EXCLUDED.sell_side_rating,
+			div_safety_grade = EXCLUDED.div_safety_grade,
+			div_growth_grade = EXCLUDED.div_growth_grade,
+			div_yield_grade = EXCLUDED.div_yield_grade,
+			div_consistency_grade = EXCLUDED.div_consistency_grade,
+			last_div_date = EXCLUDED.last_div_date,
+			div_pay_date = EXCLUDED.div_pay_date,
+			dividend_yield = EXCLUDED.dividend_yield,
+			div_yield_fwd = EXCLUDED.div_yield_fwd,
+			div_yield_4y = EXCLUDED.div_yield_4y,
+			div_rate_ttm = EXCLUDED.div_rate_ttm,
+			div_rate_fwd = EXCLUDED.div_rate_fwd,
+			payout_ratio = EXCLUDED.payout_ratio,
+			payout_ratio_4y = EXCLUDED.payout_ratio_4y,
+			div_grow_rate_3y = EXCLUDED.div_grow_rate_3y,
+			div_grow_rate_5y = EXCLUDED.div_grow_rate_5y,
+			dividend_growth = EXCLUDED.dividend_growth,
+			is_reit = EXCLUDED.is_reit,
+			is_bdc = EXCLUDED.is_bdc,
+			is_defunct = EXCLUDED.is_defunct,
+			sector = EXCLUDED.sector,
+			industry = EXCLUDED.industry,
+			earning_announce_date = EXCLUDED.earning_announce_date;
 		`,
-			r.Ticker, r.CompositeFigi, r.Date, r.MarketCap/1e6,
+			r.Ticker, r.CompositeFigi, r.Date, marketCapMil(r.MarketCap),
 			r.QuantRating, r.GrowthCategory, r.ProfitabilityCategory,
 			r.ValueCategory, r.EpsRevisionsCategory,
-			r.AuthorsRatingPro, r.SellSideRating)
+			r.AuthorsRatingPro, r.SellSideRating,
+			r.DivSafetyCategory, r.DivGrowthCategory, r.DivYieldCategory,
+			r.DivConsistencyCategory, timestampToDate(r.LastDivTimestamp),
+			timestampToDate(r.DivPayTimestamp), r.DividendYield, r.DivYieldFwd,
+			r.DivYield4y, r.DivRateTtm, r.DivRateFwd, r.PayoutRatio,
+			r.PayoutRatio4y, r.DivGrowRate3, r.DivGrowRate5, r.DividendGrowth,
+			r.IsReit, r.IsBdc, r.IsDefunct, nullString(r.Sector),
+			nullString(r.Industry), timestampToDate(r.EarningAnnounceTimestamp))
+		if err != nil {
+			log.Error().Err(err).Str("Ticker", r.Ticker).Str("CompositeFigi", r.CompositeFigi).Msg("could not save record to DB")
+			numFailed++
+		}
+	}
+
+	log.Info().Int("NumRecords", len(records)).Int("NumFailed", numFailed).Msg("records saved to DB")
+}
+
+// SaveEtfsToDB upserts ETF ratings into the seeking_alpha_etf table
+func SaveEtfsToDB(records []*EtfRecord) {
+	conn, err := pgx.Connect(context.Background(), viper.GetString("database.url"))
+	if err != nil {
+		log.Error().Err(err).Msg("Could not connect to database")
+	}
+	defer conn.Close(context.Background())
+
+	if err := MigrateEtfDB(conn); err != nil {
+		return
+	}
+
+	numFailed := 0
+
+	for _, r := range records {
+		if !isValidExchange(r) {
+			// not in a recognized exchange ... skip
+			continue
+		}
+		if r.CompositeFigi == "" {
+			log.Warn().Object("SAEtfRecord", r).Msg("skipping due to missing CompositeFigi")
+			continue
+		}
+		_, err := conn.Exec(context.Background(),
+			`INSERT INTO seeking_alpha_etf (
+			"ticker",
+			"composite_figi",
+			"event_date",
+			"quant_rating",
+			"authors_rating",
+			"momentum_grade",
+			"expenses_grade",
+			"dividends_grade",
+			"risk_grade",
+			"liquidity_grade",
+			"aum_mil",
+			"expense_ratio",
+			"dividend_yield",
+			"div_yield_fwd",
+			"div_rate_ttm",
+			"last_div_date",
+			"div_pay_date",
+			"inception_date",
+			"beta_24m"
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7,
+			$8,
+			$9,
+			$10,
+			$11,
+			$12,
+			$13,
+			$14,
+			$15,
+			$16,
+			$17,
+			$18,
+			$19
+		) ON CONFLICT ON CONSTRAINT seeking_alpha_etf_pkey
+		DO UPDATE SET
+			quant_rating = EXCLUDED.quant_rating,
+			authors_rating = EXCLUDED.authors_rating,
+			momentum_grade = EXCLUDED.momentum_grade,
+			expenses_grade = EXCLUDED.expenses_grade,
+			dividends_grade = EXCLUDED.dividends_grade,
+			risk_grade = EXCLUDED.risk_grade,
+			liquidity_grade = EXCLUDED.liquidity_grade,
+			aum_mil = EXCLUDED.aum_mil,
+			expense_ratio = EXCLUDED.expense_ratio,
+			dividend_yield = EXCLUDED.dividend_yield,
+			div_yield_fwd = EXCLUDED.div_yield_fwd,
+			div_rate_ttm = EXCLUDED.div_rate_ttm,
+			last_div_date = EXCLUDED.last_div_date,
+			div_pay_date = EXCLUDED.div_pay_date,
+			inception_date = EXCLUDED.inception_date,
+			beta_24m = EXCLUDED.beta_24m;
+		`,
+			r.Ticker, r.CompositeFigi, r.Date,
+			r.QuantRating, r.AuthorsRating, r.MomentumCategory,
+			r.ExpensesCategory, r.DividendsCategory, r.RiskCategory,
+			r.LiquidityCategory, marketCapMil(r.Aum), r.ExpenseRatio,
+			r.DividendYield, r.DivYieldFwd, r.DivRateTtm,
+			timestampToDate(r.LastDivTimestamp), timestampToDate(r.DivPayTimestamp),
+			timestampToDate(r.InceptionTimestamp), r.Beta24)
+		if err != nil {
+			log.Error().Err(err).Str("Ticker", r.Ticker).Str("CompositeFigi", r.CompositeFigi).Msg("could not save ETF record to DB")
+			numFailed++
+		}
+	}
+
+	log.Info().Int("NumRecords", len(records)).Int("NumFailed", numFailed).Msg("ETF records saved to DB")
+}
+
+// timestampToDate converts a unix timestamp reported by Seeking Alpha into a
+// date suitable for the database; a missing timestamp is stored as NULL
+func timestampToDate(ts *int64) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	dt := time.Unix(*ts, 0).UTC()
+	return &dt
+}
+
+// nullString converts an empty string to NULL
+func nullString(s string) *string {
+	if s == "" {
+		return nil
 	}
+	return &s
+}
 
-	log.Info().Int("NumRecords", len(records)).Msg("records saved to DB")
+// marketCapMil converts the market cap or assets under management reported by
+// Seeking Alpha into millions
+func marketCapMil(marketCap *float64) *float64 {
+	if marketCap == nil {
+		return nil
+	}
+	return ptr(*marketCap / 1e6)
 }
@@ -17,143 +17,187 @@ package sa
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"math"
-	"reflect"
 	"strconv"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/penny-vault/import-sa-quant-rank/common"
-	"github.com/playwright-community/playwright-go"
 	"github.com/rs/zerolog/log"
 	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/viper"
 )
 
-func Download() ([]*SeekingAlphaRecord, error) {
-	page, context, browser, pw := common.StartPlaywright(viper.GetBool("playwright.headless"))
+// Download fetches the quant ratings and metrics of every ticker in the
+// screener profile, or of the profile's tickers for a subset run, using
+// transport and stamps them with the trading session date. When archive is
+// not nil every raw response is stored in it.
+func Download[R Record](transport Transport, archive *Archive, profile *ScreenerProfile, date time.Time) ([]R, error) {
+	catalog, err := LoadCatalog[R]()
+	if err != nil {
+		log.Error().Err(err).Msg("could not load metric catalog")
+		return []R{}, err
+	}
 
-	// get time of metrics
-	today := getMarketTime()
+	f := &fetcher{
+		transport: transport,
+		archive:   archive,
+		retry:     RetryPolicyFromConfig(),
+		limiter:   RateLimiterFromConfig(),
+		workers:   viper.GetInt("workers"),
+		profile:   profile,
+	}
+	defer f.limiter.LogStats()
 
-	// start fetching metrics for each ticker
-	consolidatedMetrics := make(map[string]*SeekingAlphaRecord)
+	driftSeverity, err := DriftSeverityFromConfig()
+	if err != nil {
+		log.Error().Err(err).Msg("invalid drift severity")
+		return []R{}, err
+	}
+	drift := NewDriftReport(profile.Name, catalog)
 
-	log.Info().Time("Date", today).Msg("running Seeking Alpha quant import")
+	log.Info().Time("Date", date).Object("Profile", profile).Msg("running Seeking Alpha quant import")
 
-	// Block unnessessary requests
-	setupPageBlocks(page)
+	// make sure the session has premium access before scraping; otherwise
+	// every page would be fetched only to be rejected by validation
+	if viper.GetBool("session.preflight") {
+		if err := f.withSession(f.withChallenge(f.checkEntitlement)); err != nil {
+			log.Error().Err(err).Str("Profile", profile.Name).Msg("pre-flight entitlement check failed")
+			return []R{}, err
+		}
+	}
 
-	pageNum := 1
-	numPages := 2
+	// completed pages are checkpointed so a failed run can be resumed
+	checkpointFn := profile.CheckpointFile(viper.GetString("checkpoint.file"))
+	checkpoint := NewCheckpoint[R](checkpointFn, date)
+	if viper.GetBool("resume") {
+		if checkpoint, err = LoadCheckpoint[R](checkpointFn, date); err != nil {
+			return []R{}, err
+		}
+	}
 
-	// Load the screeners page
-	if _, err := page.Goto(SCREENER_PAGE_URL, playwright.PageGotoOptions{
-		WaitUntil: playwright.WaitUntilStateNetworkidle,
-	}); err != nil {
-		log.Error().Err(err).Msg("could not load activity page")
-		return []*SeekingAlphaRecord{}, err
+	// start fetching metrics for each ticker
+	consolidatedMetrics := checkpoint.Records
+
+	// screener pages start at 1; numPages is updated from the screener count
+	// once the first page has been fetched
+	pageNum := checkpoint.LastPage + 1
+	numPages := 1
+	if checkpoint.NumPages > 0 {
+		numPages = checkpoint.NumPages
 	}
 
 	var bar *progressbar.ProgressBar
 	if !viper.GetBool("display.hide_progress") {
 		bar = progressbar.Default(256)
+		bar.Add(checkpoint.LastPage)
 	}
 
-	for ; pageNum < numPages; pageNum++ {
-
-		// restart chromium every 5 pages to deal with strange segfault error in playwright
-		if (pageNum % 5) == 0 {
-			common.StopPlaywright(page, context, browser, pw)
-			page, context, browser, pw = common.StartPlaywright(viper.GetBool("playwright.headless"))
-			setupPageBlocks(page)
-
-			if _, err := page.Goto(SCREENER_PAGE_URL, playwright.PageGotoOptions{
-				WaitUntil: playwright.WaitUntilStateNetworkidle,
-			}); err != nil {
-				log.Error().Err(err).Msg("could not load activity page")
-				return []*SeekingAlphaRecord{}, err
-			}
-		}
-
+	for ; pageNum <= numPages; pageNum++ {
 		if !viper.GetBool("display.hide_progress") {
 			bar.Add(1)
 		}
 
 		var tickerStrs []string
-		var err error
-		tickerStrs, numPages, err = fetchScreenerResults(page, pageNum)
-		if err != nil {
+		var count int
+		if profile.IsSubset() {
+			tickerStrs, count = f.tickerChunk(pageNum)
+		} else if err := f.withSession(f.withChallenge(func() (err error) {
+			tickerStrs, count, err = f.fetchScreenerResults(pageNum)
+			return err
+		})); err != nil {
 			log.Error().Err(err).Msg("error during fetchScreenerResults")
-			return []*SeekingAlphaRecord{}, err
+			return []R{}, err
 		}
 
+		numPages = int(math.Ceil(float64(count) / float64(profile.PerPage)))
+		checkpoint.ScreenerCount = count
+		checkpoint.Slugs = append(checkpoint.Slugs, tickerStrs...)
+
 		if !viper.GetBool("display.hide_progress") {
 			bar.ChangeMax(numPages)
 		}
 
-		for _, metricsUrl := range []string{METRICS_1_URL, METRICS_2_URL, METRICS_3_URL, METRICS_4_URL, METRICS_5_URL, METRICS_6_URL, METRICS_7_URL, METRICS_8_URL, METRICS_9_URL, METRICS_10_URL, METRICS_11_URL, METRICS_12_URL} {
-			// Delay 150 ms to prevent being blocked
-			page.WaitForTimeout(150)
+		// fetch metrics
+		fetchedAt := time.Now()
+		var pageMetrics []MetricsResponse
+		if err := f.withSession(f.withChallenge(func() (err error) {
+			pageMetrics, err = f.fetchPageMetrics(catalog.Requests(), tickerStrs, pageNum)
+			return err
+		})); err != nil {
+			log.Error().Err(err).Msg("error during fetchMetricsResults")
+			return []R{}, err
+		}
 
-			// fetch metrics
-			metrics, err := fetchMetricsResults(page, metricsUrl, tickerStrs)
-			if err != nil {
-				log.Error().Err(err).Msg("error during fetchMetricsResults")
-				return []*SeekingAlphaRecord{}, err
-			}
+		// parse metrics; this is done here rather than in the workers so that
+		// consolidatedMetrics is only ever modified by a single goroutine
+		for _, metrics := range pageMetrics {
+			parseMetrics(metrics, consolidatedMetrics, catalog, drift, date, fetchedAt)
+		}
 
-			// parse metrics
-			parseMetrics(metrics, consolidatedMetrics)
+		if err := checkpoint.Save(pageNum, numPages); err != nil {
+			log.Warn().Err(err).Int("PageNum", pageNum).Msg("could not save checkpoint")
 		}
 	}
 
 	log.Info().Int("NumRecords", len(consolidatedMetrics)).Msg("loaded seeking alpha record")
-	common.StopPlaywright(page, context, browser, pw)
 
-	result := make([]*SeekingAlphaRecord, 0, len(consolidatedMetrics))
+	// the checkpoint does not keep the drift report, so a run that fails a
+	// check discards it; otherwise a resumed run would skip every page and
+	// pass the check with the same data
+	drift.Log(driftSeverity)
+	if err := drift.Check(driftSeverity); err != nil {
+		log.Error().Err(err).Str("Profile", profile.Name).Msg("schema drift check failed")
+		checkpoint.Remove()
+		return []R{}, err
+	}
+
+	reconciliation := reconcile(profile.Name, checkpoint.ScreenerCount, checkpoint.Slugs, consolidatedMetrics)
+	reconciliation.Log()
+	if err := reconciliation.Check(profile); err != nil {
+		log.Error().Err(err).Str("Profile", profile.Name).Msg("screener reconciliation failed")
+		checkpoint.Remove()
+		return []R{}, err
+	}
+
+	checkpoint.Remove()
+
+	return consolidateRecords(consolidatedMetrics, catalog), nil
+}
+
+// consolidateRecords converts the parsed metrics into the final list of records
+func consolidateRecords[R Record](consolidatedMetrics map[string]R, catalog *Catalog) []R {
+	result := make([]R, 0, len(consolidatedMetrics))
 	for _, item := range consolidatedMetrics {
-		item.Ticker = strings.ReplaceAll(strings.ToUpper(item.Ticker), ".", "/")
+		catalog.markNotReturned(item)
 		result = append(result, item)
 	}
 
-	return result, nil
+	return result
 }
 
-func parseMetrics(metricsResult MetricsResponse, consolidatedMetrics map[string]*SeekingAlphaRecord) {
-	today := getMarketTime()
-	metricTickers, metricTypes := parseMetricsMeta(metricsResult)
+func parseMetrics[R Record](metricsResult MetricsResponse, consolidatedMetrics map[string]R, catalog *Catalog, drift *DriftReport, date, fetchedAt time.Time) {
+	drift.parsedResponse()
+	metricTickers, metricTypes := parseMetricsMeta(metricsResult, drift)
 
 	for _, item := range metricsResult.Data {
 		if item.Type == "ticker_metric_grade" || item.Type == "metric" {
 			tickerId := item.Relationships.Ticker.Data.ID
-			tickerIdInt, err := strconv.Atoi(tickerId)
-			if err != nil {
-				log.Warn().
-					Str("tickerId", tickerId).
-					Msg("cannot parse int from tickerId")
+			if _, err := strconv.Atoi(tickerId); err != nil {
+				drift.add(DRIFT_ATTRIBUTE_TYPE, "relationships.ticker.id", item)
 				continue
 			}
 
 			metricId := item.Relationships.MetricType.Data.ID
 
-			var metricBundle *SeekingAlphaRecord
+			var metricBundle R
 			var ok bool
 
 			if metricBundle, ok = consolidatedMetrics[tickerId]; !ok {
 				// no current entry
 				if tickerData, ok := metricTickers[tickerId]; ok {
-					metricBundle = &SeekingAlphaRecord{
-						DateStr:        today.Format("2006-01-02"),
-						Date:           today,
-						TickerId:       tickerIdInt,
-						Ticker:         tickerData.Ticker,
-						CompanyName:    tickerData.CompanyName,
-						Exchange:       tickerData.Exchange,
-						Type:           tickerData.EquityType,
-						FollowersCount: tickerData.FollowersCount,
-					}
+					metricBundle = newRecord[R]()
+					metricBundle.setSecurity(tickerData, date, fetchedAt)
 					consolidatedMetrics[tickerId] = metricBundle
 				} else {
 					log.Warn().Str("tickerId", tickerId).Msg("cannot find ticker for associated tickerId")
@@ -163,18 +207,18 @@ func parseMetrics(metricsResult MetricsResponse, consolidatedMetrics map[string]
 
 			var metricName string
 			if metricName, ok = metricTypes[metricId]; !ok {
-				log.Warn().Str("MetricId", metricId).Msg("could not find info metric id")
+				drift.add(DRIFT_UNKNOWN_METRIC, "metric_type:"+metricId, item)
 				continue
 			}
 
-			evaluateMetrics(metricName, item, metricBundle)
+			evaluateMetrics(metricName, item, metricBundle, catalog, drift)
 		} else {
-			log.Warn().Str("Type", item.Type).Msg("unknown item type")
+			drift.add(DRIFT_UNKNOWN_TYPE, "data:"+item.Type, item)
 		}
 	}
 }
 
-func parseMetricsMeta(metricsResult MetricsResponse) (map[string]*Ticker, map[string]string) {
+func parseMetricsMeta(metricsResult MetricsResponse, drift *DriftReport) (map[string]*Ticker, map[string]string) {
 	metricTickers := make(map[string]*Ticker)
 	metricTypes := make(map[string]string)
 
@@ -185,12 +229,12 @@ func parseMetricsMeta(metricsResult MetricsResponse) (map[string]*Ticker, map[st
 
 			if companyName, ok := item.Attributes["companyName"]; ok {
 				if t.CompanyName, ok = companyName.(string); !ok {
-					log.Warn().Msg("companyName is not a string")
+					drift.addAttributeType("ticker.companyName", companyName, item)
 				}
 			}
 
 			if tickerId, err := strconv.Atoi(item.ID); err != nil {
-				log.Warn().Err(err).Msg("could not parse ticker id")
+				drift.add(DRIFT_ATTRIBUTE_TYPE, "ticker.id", item)
 				continue
 			} else {
 				t.TickerId = tickerId
@@ -198,7 +242,7 @@ func parseMetricsMeta(metricsResult MetricsResponse) (map[string]*Ticker, map[st
 
 			if ticker, ok := item.Attributes["slug"]; ok {
 				if t.Ticker, ok = ticker.(string); !ok {
-					log.Warn().Msg("ticker is not a string")
+					drift.addAttributeType("ticker.slug", ticker, item)
 				}
 			} else {
 				log.Warn().Msg("cannot get ticker name")
@@ -207,38 +251,49 @@ func parseMetricsMeta(metricsResult MetricsResponse) (map[string]*Ticker, map[st
 
 			if equityType, ok := item.Attributes["equityType"]; ok {
 				if t.EquityType, ok = equityType.(string); !ok {
-					log.Warn().Msg("equityType is not a string")
+					drift.addAttributeType("ticker.equityType", equityType, item)
 				}
 			}
 
 			if exchange, ok := item.Attributes["exchange"]; ok {
 				if t.Exchange, ok = exchange.(string); !ok {
-					log.Warn().Msg("Exchange is not a string")
+					drift.addAttributeType("ticker.exchange", exchange, item)
 				}
 			}
 
 			if isBdc, ok := item.Attributes["isBdc"]; ok {
 				if t.IsBdc, ok = isBdc.(bool); !ok {
-					log.Warn().Msg("isBdc is not a boolean")
+					drift.addAttributeType("ticker.isBdc", isBdc, item)
 				}
 			}
 
 			if isDefunct, ok := item.Attributes["isDefunct"]; ok {
 				if t.IsDefunct, ok = isDefunct.(bool); !ok {
-					log.Warn().Msg("isDefunct is not a bool")
+					drift.addAttributeType("ticker.isDefunct", isDefunct, item)
 				}
 			}
 
 			if isReit, ok := item.Attributes["isReit"]; ok {
 				if t.IsReit, ok = isReit.(bool); !ok {
-					log.Warn().Msg("isReit is not a bool")
+					drift.addAttributeType("ticker.isReit", isReit, item)
+				}
+			}
+
+			if sector, ok := item.Attributes["sectorname"]; ok {
+				if t.Sector, ok = sector.(string); !ok {
+					drift.addAttributeType("ticker.sectorname", sector, item)
+				}
+			}
+
+			if industry, ok := item.Attributes["primaryname"]; ok {
+				if t.Industry, ok = industry.(string); !ok {
+					drift.addAttributeType("ticker.primaryname", industry, item)
 				}
 			}
 
 			if followersCount, ok := item.Attributes["followersCount"]; ok {
 				if typedFollowersCount, ok := followersCount.(float64); !ok {
-					typ := reflect.TypeOf(followersCount)
-					log.Warn().Str("ExpectedType", typ.Name()).Msg("followersCount is not an int")
+					drift.addAttributeType("ticker.followersCount", followersCount, item)
 				} else {
 					t.FollowersCount = int(typedFollowersCount)
 				}
@@ -248,112 +303,208 @@ func parseMetricsMeta(metricsResult MetricsResponse) (map[string]*Ticker, map[st
 		case "metric_type":
 			if field, ok := item.Attributes["field"]; ok {
 				if metricTypes[item.ID], ok = field.(string); !ok {
-					log.Warn().Msg("field is not a string")
+					drift.addAttributeType("metric_type.field", field, item)
 				}
 			} else {
-				log.Warn().Msg("metric field name not present")
+				drift.add(DRIFT_ATTRIBUTE_TYPE, "metric_type.field as missing", item)
 				continue
 			}
 		default:
-			log.Warn().Str("Type", item.Type).Msg("unknown meta-data type")
+			drift.add(DRIFT_UNKNOWN_TYPE, "included:"+item.Type, item)
 		}
 	}
 
 	return metricTickers, metricTypes
 }
 
-func fetchMetricsResults(page playwright.Page, metricsUrl string, tickerStrs []string) (MetricsResponse, error) {
-	encodedTickers := strings.Join(tickerStrs, "%2C")
-	myUrl := fmt.Sprintf("%s%s", metricsUrl, encodedTickers)
+// fetcher requests and decodes Seeking Alpha responses for a single run
+type fetcher struct {
+	transport Transport
+	archive   *Archive
+	retry     RetryPolicy
+	limiter   *RateLimiter
+	workers   int
+	profile   *ScreenerProfile
+
+	// relogged is set once the session has been renewed during the run
+	relogged bool
+}
 
-	resp, err := page.ExpectResponse("**/api/v3/*metric*", func() error {
-		_, err := page.Evaluate(`(url) => {
-                    fetch(url);
-                }`, myUrl)
-		if err != nil {
-			log.Error().Err(err).Str("Url", myUrl).Msg("error in metrics page evaluate")
+// withSession runs fn and, the first time it fails because the session has
+// expired or lost premium access, logs in again and repeats it
+func (f *fetcher) withSession(fn func() error) error {
+	err := fn()
+	if !(errors.Is(err, ErrSessionExpired) || errors.Is(err, ErrNotEntitled)) || f.relogged {
+		return err
+	}
+
+	f.relogged = true
+	log.Warn().Err(err).Msg("seeking alpha session expired; logging in again")
+	if loginErr := relogin(f.transport); loginErr != nil {
+		log.Error().Err(loginErr).Msg("could not renew seeking alpha session")
+		return errors.Join(err, loginErr)
+	}
+
+	return fn()
+}
+
+// withChallenge returns a function that runs fn and, when it is blocked by
+// PerimeterX, asks the transport to solve the challenge and runs fn again, at
+// most challenge.max_attempts times
+func (f *fetcher) withChallenge(fn func() error) func() error {
+	return func() error {
+		err := fn()
+		if !errors.Is(err, ErrBlocked) {
+			return err
+		}
+
+		solver, ok := f.transport.(ChallengeSolver)
+		if !ok {
+			log.Error().Err(err).Msg("request was blocked and the transport cannot solve challenges")
+			return errors.Join(ErrChallengeUnsolved, err)
+		}
+
+		maxAttempts := viper.GetInt("challenge.max_attempts")
+		for attempt := 1; attempt <= maxAttempts; attempt++ {
+			log.Warn().Err(err).Int("Attempt", attempt).Int("MaxAttempts", maxAttempts).Msg("request was blocked; solving challenge")
+			if solveErr := solver.SolveChallenge(); solveErr != nil {
+				log.Error().Err(solveErr).Int("Attempt", attempt).Msg("could not solve challenge")
+				err = errors.Join(err, solveErr)
+				continue
+			}
+
+			if err = fn(); !errors.Is(err, ErrBlocked) {
+				return err
+			}
 		}
+
+		log.Error().Err(err).Int("MaxAttempts", maxAttempts).Msg("challenge is still present; giving up")
+		return errors.Join(ErrChallengeUnsolved, err)
+	}
+}
+
+// checkEntitlement runs CheckEntitlement through the rate limiter and retry
+// policy. The response is not archived because it is not part of the results.
+func (f *fetcher) checkEntitlement() error {
+	return f.retry.Do(0, "entitlement", func() error {
+		f.limiter.Wait()
+		start := time.Now()
+		err := CheckEntitlement(f.transport)
+		f.limiter.Observe(time.Since(start), err)
 		return err
 	})
-	if err != nil {
-		log.Error().Err(err).Msg("error in expect response")
-		return MetricsResponse{}, err
+}
+
+// fetchPageMetrics requests every metric group for the tickers on a page
+// using up to f.workers concurrent requests. Responses are returned in the
+// same order as requests.
+func (f *fetcher) fetchPageMetrics(requests []*MetricRequest, tickerStrs []string, pageNum int) ([]MetricsResponse, error) {
+	numWorkers := f.workers
+	if numWorkers > len(requests) {
+		numWorkers = len(requests)
+	}
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+
+	results := make([]MetricsResponse, len(requests))
+	errs := make([]error, len(requests))
+
+	// once any request fails the remaining requests are skipped
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+
+	jobs := make(chan int)
+	for ii := 0; ii < numWorkers; ii++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				if failed.Load() {
+					continue
+				}
+				results[idx], errs[idx] = f.fetchMetricsResults(requests[idx], tickerStrs, pageNum)
+				if errs[idx] != nil {
+					failed.Store(true)
+				}
+			}
+		}()
+	}
+
+	for idx := range requests {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	status := resp.Status()
+	return results, nil
+}
+
+func (f *fetcher) fetchMetricsResults(request *MetricRequest, tickerStrs []string, pageNum int) (MetricsResponse, error) {
 	var metricsResult MetricsResponse
-	if status == 200 {
-		err = resp.JSON(&metricsResult)
+	err := f.retry.Do(pageNum, request.Group, func() error {
+		f.limiter.Wait()
+		start := time.Now()
+		body, err := f.transport.Metrics(request, tickerStrs)
+		f.limiter.Observe(time.Since(start), err)
+		status, raw := responseStatus(body, err)
+		f.archive.Add(pageNum, request.Group, metricsUrl(request, tickerStrs), status, raw)
+
 		if err != nil {
-			log.Error().Err(err).Msg("error deserializing JSON for metrics response")
-			return MetricsResponse{}, errors.New("error deserializing JSON for metrics response")
+			return classifySession(classifyBlock(err))
 		}
-	} else {
-		log.Error().Int("Status", status).Msg("metrics response has invalid status")
-		return MetricsResponse{}, errors.New("metrics response has invalid status")
+
+		metricsResult = MetricsResponse{}
+		return decodeResponse(body, &metricsResult)
+	})
+	if err != nil {
+		log.Error().Err(err).Int("PageNum", pageNum).Str("Endpoint", request.Group).Msg("error fetching metrics response")
+		return MetricsResponse{}, err
 	}
 
 	return metricsResult, nil
 }
 
-func fetchScreenerResults(page playwright.Page, pageNum int) ([]string, int, error) {
-	screenerArguments := ScreenerArguments{
-		Filter: FilterGroup{
-			QuantRating: FilterDef{
-				Gte:     1,
-				Lte:     5,
-				Exclude: false,
-			},
-		},
-		Page:       pageNum,
-		PerPage:    100,
-		Sort:       nil,
-		TotalCount: true,
-		Type:       "stock",
-	}
+// fetchScreenerResults returns the ticker slugs on pageNum along with the
+// total number of tickers matching the screener
+func (f *fetcher) fetchScreenerResults(pageNum int) ([]string, int, error) {
+	screenerArguments := f.profile.Arguments(pageNum)
 
 	args, err := json.Marshal(screenerArguments)
 	if err != nil {
 		log.Warn().Err(err).Msg("could not marshal screener arguments")
 	}
 
-	resp, err := page.ExpectResponse(SCREENER_API_URL, func() error {
-		_, err := page.Evaluate(`(params) => {
-            fetch('https://seekingalpha.com/api/v3/screener_results', {
-                method: 'POST',
-                cache: 'no-cache',
-                headers: {
-                    'Content-Type': 'application/json'
-                },
-                body: params,
-            });
-        }`, string(args))
+	var screenerData ScreenerResponse
+	err = f.retry.Do(pageNum, ARCHIVE_SCREENER_ENDPOINT, func() error {
+		f.limiter.Wait()
+		start := time.Now()
+		body, err := f.transport.Screener(pageNum, args)
+		f.limiter.Observe(time.Since(start), err)
+		status, raw := responseStatus(body, err)
+		f.archive.Add(pageNum, ARCHIVE_SCREENER_ENDPOINT, SCREENER_API_URL, status, raw)
+
 		if err != nil {
-			log.Error().Err(err).Msg("error in page evaluate")
+			return classifySession(classifyBlock(err))
 		}
-		return err
+
+		screenerData = ScreenerResponse{}
+		return decodeResponse(body, &screenerData)
 	})
 	if err != nil {
-		log.Error().Err(err).Msg("failed waiting for response for POST SCREENER_URL")
+		log.Error().Err(err).Int("PageNum", pageNum).Msg("error fetching SCREENER_URL response")
 		return []string{}, 0, err
 	}
 
-	status := resp.Status()
-
-	var screenerData ScreenerResponse
-	if status == 200 {
-		if err := resp.JSON(&screenerData); err != nil {
-			log.Error().Err(err).Msg("error parsing JSON response for SCREENER_URL")
-			return []string{}, 0, err
-		}
-	} else {
-		log.Error().Int("Status", status).Int("PageNum", pageNum).Msg("invalid status received from POST SCREENER_URL")
-		return []string{}, 0, errors.New("invalid screener API status returned")
-	}
-
-	if screenerData.Meta.Count < 3000 {
-		log.Error().Int("Count", screenerData.Meta.Count).Msg("Num tickers matching screen is below threshold")
-		return []string{}, 0, errors.New("tickers returned below threshold")
+	if screenerData.Meta.Count < f.profile.MinCount {
+		log.Error().Int("Count", screenerData.Meta.Count).Int("MinCount", f.profile.MinCount).Str("Profile", f.profile.Name).Msg("Num tickers matching screen is below threshold")
+		return []string{}, 0, ErrUniverseTooSmall
 	}
 
 	tickerStrs := make([]string, 0, len(screenerData.Data))
@@ -361,862 +512,71 @@ func fetchScreenerResults(page playwright.Page, pageNum int) ([]string, int, err
 		tickerStrs = append(tickerStrs, item.Attributes.Slug)
 	}
 
-	// recalculate the number of pages
-	numPages := int(math.Ceil(float64(screenerData.Meta.Count) / 100.0))
-
-	return tickerStrs, numPages, nil
+	return tickerStrs, screenerData.Meta.Count, nil
 }
 
-func getMarketTime() time.Time {
-	nyc, err := time.LoadLocation("America/New_York")
-	if err != nil {
-		log.Error().Err(err).Msg("could not load timezone")
+// tickerChunk returns the slugs of a subset run on pageNum along with the total
+// number of slugs, mirroring the paging of the screener
+func (f *fetcher) tickerChunk(pageNum int) ([]string, int) {
+	tickers := f.profile.Tickers
+	start := (pageNum - 1) * f.profile.PerPage
+	if start > len(tickers) {
+		start = len(tickers)
+	}
+	end := start + f.profile.PerPage
+	if end > len(tickers) {
+		end = len(tickers)
 	}
-	now := time.Now()
-	today := time.Date(now.Year(), now.Month(), now.Day(), 9, 30, 0, 0, nyc)
-	return today
+	return tickers[start:end], len(tickers)
 }
 
-func setupPageBlocks(page playwright.Page) {
-	// block a variety of domains that contain trackers and ads
-	page.Route("**/*", func(route playwright.Route) {
-		request := route.Request()
-		if strings.Contains(request.URL(), "google.com") ||
-			strings.Contains(request.URL(), "facebook.com") ||
-			strings.Contains(request.URL(), "adsystem.com") ||
-			strings.Contains(request.URL(), "sitescout.com") ||
-			strings.Contains(request.URL(), "ipredictive.com") ||
-			strings.Contains(request.URL(), "eyeota.net") ||
-			// strings.Contains(request.URL(), "collect") ||
-			strings.Contains(request.URL(), "beacon") ||
-			strings.Contains(request.URL(), "mone") ||
-			strings.Contains(request.URL(), "mone_event") {
-			err := route.Abort("failed")
-			if err != nil {
-				log.Error().Err(err).Msg("failed blocking route")
-			}
-			return
-		}
-
-		if request.ResourceType() == "image" {
-			err := route.Abort("failed")
-			if err != nil {
-				log.Error().Err(err).Msg("failed blocking image")
-			}
-		}
-
-		route.Continue()
-	})
-}
+// evaluateMetrics stores the value of a metric item in the record column
+// declared by the metric catalog
+func evaluateMetrics(metricName string, item MetricItem, metricBundle Record, catalog *Catalog, drift *DriftReport) {
+	metric, ok := catalog.Metric(metricName)
+	if !ok {
+		drift.add(DRIFT_UNKNOWN_METRIC, metricName, item)
+		return
+	}
+	drift.receive(metricName)
 
-func evaluateMetrics(metricName string, item MetricItem, metricBundle *SeekingAlphaRecord) {
-	var ok bool
-	switch metricName {
-	case "marketcap_display":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if metricBundle.MarketCap, ok = item.Attributes["value"].(float64); !ok {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "quant_rating":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if quantRating, ok := item.Attributes["value"].(float64); !ok {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		} else {
-			metricBundle.QuantRating = float32(quantRating)
-		}
-	case "authors_rating":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theAuthorsRatingPro, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.AuthorsRatingPro = float32(theAuthorsRatingPro)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "sell_side_rating":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theSellSideRating, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.SellSideRating = float32(theSellSideRating)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "value_category":
-		if theValueCategory, ok := item.Attributes["grade"].(float64); ok {
-			metricBundle.ValueCategory = float32(theValueCategory)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "growth_category":
-		if theGrowthCategory, ok := item.Attributes["grade"].(float64); ok {
-			metricBundle.GrowthCategory = float32(theGrowthCategory)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "profitability_category":
-		if theProfitabilityCategory, ok := item.Attributes["grade"].(float64); ok {
-			metricBundle.ProfitabilityCategory = float32(theProfitabilityCategory)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "momentum_category":
-		if theMomentumCategory, ok := item.Attributes["grade"].(float64); ok {
-			metricBundle.MomentumCategory = float32(theMomentumCategory)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "eps_revisions_category":
-		if theEpsRevisionsCategory, ok := item.Attributes["grade"].(float64); ok {
-			metricBundle.EpsRevisionsCategory = float32(theEpsRevisionsCategory)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "earning_announce_date":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theEarningAnnounceTimestamp, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.EarningAnnounceTimestamp = int64(theEarningAnnounceTimestamp)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "eps_estimate_fy1":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if metricBundle.EpsEstimateFy1, ok = item.Attributes["value"].(float64); !ok {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "revenue_estimate":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if metricBundle.RevenueEstimate, ok = item.Attributes["value"].(float64); !ok {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "eps_normalized_actual":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theEpsNormalizedActual, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.EpsNormalizedActual = float32(theEpsNormalizedActual)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "eps_surprise":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theEpsSurprise, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.EpsSurprise = float32(theEpsSurprise)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "revenue_actual":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if metricBundle.RevenueActual, ok = item.Attributes["value"].(float64); !ok {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "revenue_surprise":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if metricBundle.RevenueSurprise, ok = item.Attributes["value"].(float64); !ok {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "tev":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if metricBundle.Tev, ok = item.Attributes["value"].(float64); !ok {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "pe_ratio":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
+	if metric.Meaningful {
+		meaningful, ok := item.Attributes["meaningful"].(bool)
+		if !ok {
+			drift.addAttributeType(metricName+".meaningful", item.Attributes["meaningful"], item)
+			metricBundle.setMissing(metricName, ReasonTypeMismatch)
 			return
 		}
 		if !meaningful {
+			metricBundle.setMissing(metricName, ReasonNotMeaningful)
 			return
 		}
-		if thePeRatio, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.PeRatio = float32(thePeRatio)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "pe_nongaap_fy1":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if thePeNonGaapFy1, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.PeNonGaapFy1 = float32(thePeNonGaapFy1)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "ps_ratio":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if thePsRatio, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.PsRatio = float32(thePsRatio)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "ev_12m_sales_ratio":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theEv12mSalesRatio, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.Ev12mSalesRatio = float32(theEv12mSalesRatio)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "ev_ebitda":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theEvEbitda, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.EvEbitda = float32(theEvEbitda)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "pb_ratio":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if thePbRatio, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.PbRatio = float32(thePbRatio)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "price_cf_ratio":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if thePriceCfRatio, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.PriceCfRatio = float32(thePriceCfRatio)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "revenue_growth":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theRevenueGrowth, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.RevenueGrowth = float32(theRevenueGrowth)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "revenue_change_display":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theRevenueChange, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.RevenueChange = float32(theRevenueChange)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "revenue_growth3":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
+	}
+
+	attribute, attributeVal := metric.attribute(item)
+	switch val := attributeVal.(type) {
+	case float64:
+		metric.set(metricBundle, val)
+		metricBundle.clearMissing(metricName)
+	case string:
+		// dates may be reported as YYYY-MM-DD rather than a unix timestamp
+		if metric.Type != "timestamp" {
+			drift.addAttributeType(metricName+"."+attribute, val, item)
+			metricBundle.setMissing(metricName, ReasonTypeMismatch)
 			return
 		}
-		if !meaningful {
+		dt, err := time.Parse("2006-01-02", val)
+		if err != nil {
+			drift.add(DRIFT_ATTRIBUTE_TYPE, metricName+"."+attribute+" as non-date string", item)
+			metricBundle.setMissing(metricName, ReasonTypeMismatch)
 			return
 		}
-		if theRevenueGrowth3, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.RevenueGrowth3 = float32(theRevenueGrowth3)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "ebitda_yoy":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theEbitdaYoy, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.EbitdaYoy = float32(theEbitdaYoy)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "ebitda_3y_cagr":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theEbitda3yCagr, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.Ebitda3yCagr = float32(theEbitda3yCagr)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "net_income_3y_cagr":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theNetIncome3yCagr, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.NetIncome3yCagr = float32(theNetIncome3yCagr)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "diluted_eps_growth":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theDilutedEpsGrowth, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.DilutedEpsGrowth = float32(theDilutedEpsGrowth)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "earnings_growth_3y_cagr":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theEarningsGrowth3yCagr, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.EarningsGrowth3yCagr = float32(theEarningsGrowth3yCagr)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "tangible_book_value_3y_cagr":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theTangibleBookValue3yCagr, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.TangibleBookValue3yCagr = float32(theTangibleBookValue3yCagr)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "total_assets_3y_cagr":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theTotalAssets3yCagr, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.TotalAssets3yCagr = float32(theTotalAssets3yCagr)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "total_revenue":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if metricBundle.TotalRevenue, ok = item.Attributes["value"].(float64); !ok {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "net_income":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if metricBundle.NetIncome, ok = item.Attributes["value"].(float64); !ok {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "cash_from_operations_as_reported":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if metricBundle.CashFromOperationsAsReported, ok = item.Attributes["value"].(float64); !ok {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "gross_margin":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theGrossMargin, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.GrossMargin = float32(theGrossMargin)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "ebit_margin":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theEbitMargin, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.EbitMargin = float32(theEbitMargin)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "ebitda_margin":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theEbitdaMargin, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.EbitdaMargin = float32(theEbitdaMargin)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "net_margin":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theNetMargin, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.NetMargin = float32(theNetMargin)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "levered_fcf_margin":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theLeveredFcfMargin, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.LeveredFcfMargin = float32(theLeveredFcfMargin)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "roe":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theRoe, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.Roe = float32(theRoe)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "return_on_avg_tot_assets":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theReturnOnAvgTotAssets, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.ReturnOnAvgTotAssets = float32(theReturnOnAvgTotAssets)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "return_on_total_capital":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theReturnOnTotalCapital, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.ReturnOnTotalCapital = float32(theReturnOnTotalCapital)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "assets_turnover":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theAssetsTurnover, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.AssetsTurnover = float32(theAssetsTurnover)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "net_inc_per_employee":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if metricBundle.NetIncPerEmployee, ok = item.Attributes["value"].(float64); !ok {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "capex_to_sales":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theCapexToSales, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.CapexToSales = float32(theCapexToSales)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "short_interest_percent_of_float":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theShortInterestPercentOfFloat, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.ShortInterestPercentOfFloat = float32(theShortInterestPercentOfFloat)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "short_interest_coverage_ratio":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theShortInterestCoverageRatio, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.ShortInterestCoverageRatio = float32(theShortInterestCoverageRatio)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "beta24":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theBeta24, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.Beta24 = float32(theBeta24)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "altman_z_score":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theAltmanZScore, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.AltmanZScore = float32(theAltmanZScore)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "shares":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theShares, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.Shares = int64(theShares)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "float_percent":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theFloatPercent, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.FloatPercent = float32(theFloatPercent)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "insiders_shares":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theInsiderShares, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.InsidersShares = int64(theInsiderShares)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "insiders_share_percent":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if metricBundle.InsidersSharePercent, ok = item.Attributes["value"].(float64); !ok {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "institutions_shares":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theInstitutionsShares, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.InstitutionsShares = int64(theInstitutionsShares)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "institutions_share_percent":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if metricBundle.InstitutionsSharePercent, ok = item.Attributes["value"].(float64); !ok {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "total_debt":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if metricBundle.TotalDebt, ok = item.Attributes["value"].(float64); !ok {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "debt_long_term":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if metricBundle.DebtLongTerm, ok = item.Attributes["value"].(float64); !ok {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "total_cash":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if metricBundle.TotalCash, ok = item.Attributes["value"].(float64); !ok {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "debt_fcf":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theDebtFcf, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.DebtFcf = float32(theDebtFcf)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "current_ratio":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theCurrentRatio, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.CurrentRatio = float32(theCurrentRatio)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "quick_ratio":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theQuickRatio, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.QuickRatio = float32(theQuickRatio)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "interest_coverage_ratio":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theInterestCoverageRatio, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.InterestCoverageRatio = float32(theInterestCoverageRatio)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "debt_eq":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theDebtEq, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.DebtEq = float32(theDebtEq)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
-	case "long_term_debt_per_capital":
-		var meaningful bool
-		if meaningful, ok = item.Attributes["meaningful"].(bool); !ok {
-			return
-		}
-		if !meaningful {
-			return
-		}
-		if theLongTermDebtPerCapital, ok := item.Attributes["value"].(float64); ok {
-			metricBundle.LongTermDebtPerCapital = float32(theLongTermDebtPerCapital)
-		} else {
-			log.Warn().Str("metricName", metricName).Msg("could not convert value")
-		}
+		metric.set(metricBundle, float64(dt.Unix()))
+		metricBundle.clearMissing(metricName)
+	case nil:
+		metricBundle.setMissing(metricName, ReasonNotReturned)
+	default:
+		drift.addAttributeType(metricName+"."+attribute, val, item)
+		metricBundle.setMissing(metricName, ReasonTypeMismatch)
 	}
 }
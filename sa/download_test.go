@@ -0,0 +1,316 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// fakeSeekingAlpha serves the screener and metrics endpoints of the Seeking
+// Alpha API for the tickers in universe. Every metric of a ticker is reported
+// as the ticker's position in universe plus one.
+type fakeSeekingAlpha struct {
+	universe []string
+
+	// fail, when set, answers a request instead of the API
+	fail func(w http.ResponseWriter, r *http.Request) bool
+
+	// drift adds a metric of an undeclared metric type to every response
+	drift bool
+
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+func (sa *fakeSeekingAlpha) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sa.mu.Lock()
+	sa.requests[r.URL.Path]++
+	sa.mu.Unlock()
+
+	if sa.fail != nil && sa.fail(w, r) {
+		return
+	}
+
+	switch r.URL.Path {
+	case "/api/v3/screener_results":
+		sa.screener(w, r)
+	case "/api/v3/metrics", "/api/v3/ticker_metric_grades":
+		sa.metrics(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (sa *fakeSeekingAlpha) screener(w http.ResponseWriter, r *http.Request) {
+	var args ScreenerArguments
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	start := (args.Page - 1) * args.PerPage
+	end := start + args.PerPage
+	if end > len(sa.universe) {
+		end = len(sa.universe)
+	}
+
+	resp := ScreenerResponse{Meta: ScreenerResponseMeta{Count: len(sa.universe)}}
+	for idx := start; idx < end; idx++ {
+		resp.Data = append(resp.Data, ScreenerItem{
+			ID:         strconv.Itoa(idx + 1),
+			Type:       "ticker",
+			Attributes: TickerAttributes{Slug: sa.universe[idx]},
+		})
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (sa *fakeSeekingAlpha) metrics(w http.ResponseWriter, r *http.Request) {
+	fields := strings.Split(r.URL.Query().Get("filter[fields]"), ",")
+	slugs := strings.Split(r.URL.Query().Get("filter[slugs]"), ",")
+
+	resp := MetricsResponse{}
+	for fieldIdx, field := range fields {
+		resp.Meta = append(resp.Meta, MetricsMeta{
+			ID:         strconv.Itoa(fieldIdx + 1),
+			Type:       "metric_type",
+			Attributes: map[string]any{"field": field},
+		})
+	}
+
+	for _, slug := range slugs {
+		tickerId := sa.tickerId(slug)
+		resp.Meta = append(resp.Meta, MetricsMeta{
+			ID:   strconv.Itoa(tickerId),
+			Type: "ticker",
+			Attributes: map[string]any{
+				"slug":        slug,
+				"companyName": strings.ToUpper(slug) + " Inc",
+				"equityType":  "stocks",
+				"exchange":    "NYSE",
+			},
+		})
+
+		for fieldIdx := range fields {
+			item := MetricItem{
+				Type: "metric",
+				Attributes: map[string]any{
+					"value":      float64(tickerId),
+					"grade":      float64(tickerId),
+					"meaningful": true,
+				},
+			}
+			item.Relationships.MetricType.Data.ID = strconv.Itoa(fieldIdx + 1)
+			item.Relationships.Ticker.Data.ID = strconv.Itoa(tickerId)
+			resp.Data = append(resp.Data, item)
+		}
+
+		if sa.drift {
+			item := MetricItem{Type: "metric", Attributes: map[string]any{"value": 1.0}}
+			item.Relationships.MetricType.Data.ID = "999"
+			item.Relationships.Ticker.Data.ID = strconv.Itoa(tickerId)
+			resp.Data = append(resp.Data, item)
+		}
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+// tickerId returns the position of slug in the universe plus one
+func (sa *fakeSeekingAlpha) tickerId(slug string) int {
+	for idx, other := range sa.universe {
+		if other == slug {
+			return idx + 1
+		}
+	}
+	return 0
+}
+
+func (sa *fakeSeekingAlpha) numRequests(path string) int {
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+	return sa.requests[path]
+}
+
+// newDownload starts a server for fake and returns a function that downloads
+// its universe through an HttpTransport with the given number of tickers per
+// screener page. Every download shares the checkpoint file.
+func newDownload(t *testing.T, fake *fakeSeekingAlpha, perPage int) func() ([]*SeekingAlphaRecord, error) {
+	t.Helper()
+
+	fake.requests = make(map[string]int)
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	stateFile := filepath.Join(dir, "state.json")
+	state := `{"cookies":[{"name":"session_token","value":"abc","domain":".seekingalpha.com","path":"/"}],"origins":[],"userAgent":"test"}`
+	if err := os.WriteFile(stateFile, []byte(state), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("http.base_url", server.URL)
+	viper.Set("display.hide_progress", true)
+	viper.Set("checkpoint.file", filepath.Join(dir, "sa-checkpoint.json"))
+	viper.Set("retry.max_attempts", 3)
+	viper.Set("challenge.max_attempts", 1)
+
+	transport, err := NewHttpTransport(&Account{Name: DEFAULT_ACCOUNT, StateFile: stateFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { transport.Close() })
+
+	profile := &ScreenerProfile{
+		Name:    "test",
+		Output:  "sa-test",
+		Type:    SECURITY_TYPE_STOCK,
+		PerPage: perPage,
+	}
+
+	date := time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC)
+	return func() ([]*SeekingAlphaRecord, error) {
+		return Download[*SeekingAlphaRecord](transport, nil, profile, date)
+	}
+}
+
+// runDownload downloads the universe of fake once
+func runDownload(t *testing.T, fake *fakeSeekingAlpha, perPage int) ([]*SeekingAlphaRecord, error) {
+	t.Helper()
+	return newDownload(t, fake, perPage)()
+}
+
+func TestDownloadMultiplePages(t *testing.T) {
+	fake := &fakeSeekingAlpha{universe: []string{"aapl", "msft", "goog", "amzn", "meta"}}
+
+	records, err := runDownload(t, fake, 2)
+	if err != nil {
+		t.Fatalf("Download returned error: %v", err)
+	}
+
+	if n := fake.numRequests("/api/v3/screener_results"); n != 3 {
+		t.Errorf("expected 3 screener requests, got %d", n)
+	}
+
+	if len(records) != len(fake.universe) {
+		t.Fatalf("expected %d records, got %d", len(fake.universe), len(records))
+	}
+
+	for _, record := range records {
+		expected := fake.tickerId(strings.ToLower(record.Ticker))
+		if expected == 0 {
+			t.Errorf("unexpected ticker %s", record.Ticker)
+			continue
+		}
+
+		if record.CompanyName != record.Ticker+" Inc" {
+			t.Errorf("%s: unexpected company name %s", record.Ticker, record.CompanyName)
+		}
+
+		if record.QuantRating == nil || *record.QuantRating != float32(expected) {
+			t.Errorf("%s: expected quant rating %d, got %v", record.Ticker, expected, record.QuantRating)
+		}
+
+		if record.DivSafetyCategory == nil || *record.DivSafetyCategory != float32(expected) {
+			t.Errorf("%s: expected div safety grade %d, got %v", record.Ticker, expected, record.DivSafetyCategory)
+		}
+
+		if record.LastDivTimestamp == nil || *record.LastDivTimestamp != int64(expected) {
+			t.Errorf("%s: expected last dividend timestamp %d, got %v", record.Ticker, expected, record.LastDivTimestamp)
+		}
+	}
+}
+
+func TestDownloadSessionExpired(t *testing.T) {
+	fake := &fakeSeekingAlpha{
+		universe: []string{"aapl", "msft"},
+		fail: func(w http.ResponseWriter, r *http.Request) bool {
+			if r.URL.Path != "/api/v3/metrics" {
+				return false
+			}
+			http.Error(w, `{"errors":[{"status":"401"}]}`, http.StatusUnauthorized)
+			return true
+		},
+	}
+
+	_, err := runDownload(t, fake, 2)
+	if !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("expected ErrSessionExpired, got %v", err)
+	}
+
+	// a 401 is not retried and automatic login is disabled
+	if n := fake.numRequests("/api/v3/metrics"); n != 1 {
+		t.Errorf("expected 1 metrics request, got %d", n)
+	}
+}
+
+func TestDownloadChallenge(t *testing.T) {
+	fake := &fakeSeekingAlpha{
+		universe: []string{"aapl", "msft"},
+		fail: func(w http.ResponseWriter, r *http.Request) bool {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><div id="px-captcha"></div></body></html>`))
+			return true
+		},
+	}
+
+	_, err := runDownload(t, fake, 2)
+	if !errors.Is(err, ErrChallengeUnsolved) {
+		t.Fatalf("expected ErrChallengeUnsolved, got %v", err)
+	}
+	if !errors.Is(err, ErrBlocked) {
+		t.Errorf("expected ErrBlocked, got %v", err)
+	}
+
+	// a challenge is not retried and the http transport cannot solve it
+	if n := fake.numRequests("/api/v3/screener_results"); n != 1 {
+		t.Errorf("expected 1 screener request, got %d", n)
+	}
+}
+
+func TestDownloadResumeAfterFailedDriftCheck(t *testing.T) {
+	fake := &fakeSeekingAlpha{universe: []string{"aapl", "msft", "goog"}, drift: true}
+	download := newDownload(t, fake, 2)
+	viper.Set("drift.severity", DRIFT_SEVERITY_ERROR)
+
+	if _, err := download(); err == nil {
+		t.Fatal("expected the drift check to fail")
+	}
+
+	// the resumed run fetches the pages again and fails the same check
+	viper.Set("resume", true)
+	if _, err := download(); err == nil {
+		t.Fatal("expected the drift check of the resumed run to fail")
+	}
+
+	if n := fake.numRequests("/api/v3/screener_results"); n != 4 {
+		t.Errorf("expected 4 screener requests, got %d", n)
+	}
+}
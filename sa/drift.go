@@ -0,0 +1,228 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// Kinds of schema drift in Seeking Alpha responses
+const (
+	DRIFT_UNKNOWN_METRIC string = "unknown_metric"
+	DRIFT_ATTRIBUTE_TYPE string = "attribute_type"
+	DRIFT_UNKNOWN_TYPE   string = "unknown_type"
+	DRIFT_NOT_RECEIVED   string = "not_received"
+
+	// DRIFT_UNDECODABLE is an archived metrics response with status 200
+	// that is not JSON, e.g. a truncated body or a block page
+	DRIFT_UNDECODABLE string = "undecodable_response"
+)
+
+// maxDriftExampleLength truncates the example payload of a drift entry
+const maxDriftExampleLength = 1024
+
+// Drift severities; error fails the run when any drift is found
+const (
+	DRIFT_SEVERITY_IGNORE string = "ignore"
+	DRIFT_SEVERITY_WARN   string = "warn"
+	DRIFT_SEVERITY_ERROR  string = "error"
+)
+
+// DriftEntry counts the occurrences of a single kind of unexpected response
+// content and keeps the first payload it was seen in
+type DriftEntry struct {
+	Kind    string
+	Key     string
+	Count   int
+	Example string
+}
+
+// DriftReport collects the differences between the Seeking Alpha responses of
+// a run and what the parser expects, so that API changes are reported once per
+// run instead of once per item
+type DriftReport struct {
+	Profile string
+
+	entries  map[string]*DriftEntry
+	expected []string
+	received map[string]bool
+	parsed   int
+}
+
+// NewDriftReport creates an empty report that expects every metric in catalog
+// to be returned
+func NewDriftReport(profile string, catalog *Catalog) *DriftReport {
+	expected := make([]string, 0, len(catalog.Metrics))
+	for _, metric := range catalog.Metrics {
+		expected = append(expected, metric.Field)
+	}
+
+	return &DriftReport{
+		Profile:  profile,
+		entries:  make(map[string]*DriftEntry),
+		expected: expected,
+		received: make(map[string]bool),
+	}
+}
+
+// add records an occurrence of drift; example is the item it was found in
+func (report *DriftReport) add(kind, key string, example any) {
+	log.Debug().Str("Kind", kind).Str("Key", key).Msg("unexpected content in Seeking Alpha response")
+
+	id := kind + "/" + key
+	if entry, ok := report.entries[id]; ok {
+		entry.Count++
+		return
+	}
+
+	payload, err := json.Marshal(example)
+	if err != nil {
+		payload = []byte(fmt.Sprintf("%v", example))
+	}
+	if len(payload) > maxDriftExampleLength {
+		payload = payload[:maxDriftExampleLength]
+	}
+
+	report.entries[id] = &DriftEntry{
+		Kind:    kind,
+		Key:     key,
+		Count:   1,
+		Example: string(payload),
+	}
+}
+
+// addAttributeType records an attribute whose value has an unexpected type
+func (report *DriftReport) addAttributeType(path string, val any, example any) {
+	report.add(DRIFT_ATTRIBUTE_TYPE, fmt.Sprintf("%s as %s", path, jsonType(val)), example)
+}
+
+// receive marks a metric field as returned by Seeking Alpha
+func (report *DriftReport) receive(field string) {
+	report.received[field] = true
+}
+
+// parsedResponse counts a metrics response that was parsed
+func (report *DriftReport) parsedResponse() {
+	report.parsed++
+}
+
+// Entries returns the drift found in the run sorted by kind and key. Metrics
+// that were requested but never returned are only reported once at least one
+// metrics response has been parsed.
+func (report *DriftReport) Entries() []*DriftEntry {
+	entries := make([]*DriftEntry, 0, len(report.entries))
+	for _, entry := range report.entries {
+		entries = append(entries, entry)
+	}
+
+	if report.parsed > 0 {
+		for _, field := range report.expected {
+			if !report.received[field] {
+				entries = append(entries, &DriftEntry{
+					Kind: DRIFT_NOT_RECEIVED,
+					Key:  field,
+				})
+			}
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Kind != entries[j].Kind {
+			return entries[i].Kind < entries[j].Kind
+		}
+		return entries[i].Key < entries[j].Key
+	})
+
+	return entries
+}
+
+// Log writes the report at the level matching severity
+func (report *DriftReport) Log(severity string) {
+	entries := report.Entries()
+
+	level := zerolog.WarnLevel
+	switch severity {
+	case DRIFT_SEVERITY_IGNORE:
+		level = zerolog.DebugLevel
+	case DRIFT_SEVERITY_ERROR:
+		level = zerolog.ErrorLevel
+	}
+
+	if len(entries) == 0 {
+		log.Info().Str("Profile", report.Profile).Int("NumResponses", report.parsed).Msg("no schema drift in Seeking Alpha responses")
+		return
+	}
+
+	for _, entry := range entries {
+		event := log.WithLevel(level).Str("Profile", report.Profile).Str("Kind", entry.Kind).Str("Key", entry.Key)
+		if entry.Kind != DRIFT_NOT_RECEIVED {
+			event = event.Int("Count", entry.Count).Str("Example", entry.Example)
+		}
+		event.Msg("schema drift")
+	}
+
+	log.WithLevel(level).Str("Profile", report.Profile).Int("NumResponses", report.parsed).Int("NumDrift", len(entries)).Msg("Seeking Alpha responses differ from the expected schema")
+}
+
+// DriftSeverityFromConfig reads the drift.severity configuration value
+func DriftSeverityFromConfig() (string, error) {
+	severity := viper.GetString("drift.severity")
+	switch severity {
+	case "":
+		return DRIFT_SEVERITY_WARN, nil
+	case DRIFT_SEVERITY_IGNORE, DRIFT_SEVERITY_WARN, DRIFT_SEVERITY_ERROR:
+		return severity, nil
+	default:
+		return "", fmt.Errorf("unknown drift severity %s", severity)
+	}
+}
+
+// Check returns an error if drift was found and severity is error
+func (report *DriftReport) Check(severity string) error {
+	if severity != DRIFT_SEVERITY_ERROR {
+		return nil
+	}
+
+	if n := len(report.Entries()); n > 0 {
+		return fmt.Errorf("%d kinds of schema drift in Seeking Alpha responses", n)
+	}
+	return nil
+}
+
+// jsonType names the JSON type of a decoded value
+func jsonType(val any) string {
+	switch val.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", val)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	_ "embed"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// metrics_etf.toml declares the metrics requested for ETF screener profiles.
+// It can be extended or overridden with the file named by the
+// catalog.etf_file configuration value.
+
+//go:embed metrics_etf.toml
+var defaultEtfCatalog []byte
+
+// EtfRecord holds the Seeking Alpha ratings and fund metrics of an ETF
+type EtfRecord struct {
+	DateStr            string `json:"date" parquet:"name=Date, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Date               time.Time
+	TickerId           int                `json:"tickerId" parquet:"name=SeekingAlphaTickerId, type=INT32"`
+	Ticker             string             `json:"ticker" parquet:"name=Ticker, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	CompositeFigi      string             `json:"compositeFigi" parquet:"name=CompositeFigi, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	CompanyName        string             `json:"companyName" parquet:"name=FundName, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Exchange           string             `json:"exchange" parquet:"name=Exchange, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Type               string             `json:"type" parquet:"name=Type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	FollowersCount     int                `json:"followersCount" parquet:"name=FollowersCount, type=INT32"`
+	FetchedAt          int64              `json:"fetchedAt" parquet:"name=FetchedAt, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	QuantRating        *float32           `json:"quant_rating" parquet:"name=QuantRating, type=FLOAT, repetitiontype=OPTIONAL"`
+	AuthorsRating      *float32           `json:"authors_rating" parquet:"name=AuthorsRating, type=FLOAT, repetitiontype=OPTIONAL"`
+	MomentumCategory   *float32           `json:"momentum_category" parquet:"name=MomentumGrade, type=FLOAT, repetitiontype=OPTIONAL"`
+	ExpensesCategory   *float32           `json:"expenses_category" parquet:"name=ExpensesGrade, type=FLOAT, repetitiontype=OPTIONAL"`
+	DividendsCategory  *float32           `json:"dividends_category" parquet:"name=DividendsGrade, type=FLOAT, repetitiontype=OPTIONAL"`
+	RiskCategory       *float32           `json:"risk_category" parquet:"name=RiskGrade, type=FLOAT, repetitiontype=OPTIONAL"`
+	LiquidityCategory  *float32           `json:"liquidity_category" parquet:"name=LiquidityGrade, type=FLOAT, repetitiontype=OPTIONAL"`
+	Aum                *float64           `json:"aum" parquet:"name=AssetsUnderManagement, type=DOUBLE, repetitiontype=OPTIONAL"`
+	ExpenseRatio       *float32           `json:"expense_ratio" parquet:"name=ExpenseRatio, type=FLOAT, repetitiontype=OPTIONAL"`
+	DividendYield      *float32           `json:"dividend_yield" parquet:"name=DividendYield, type=FLOAT, repetitiontype=OPTIONAL"`
+	DivYieldFwd        *float32           `json:"div_yield_fwd" parquet:"name=DividendYieldForward, type=FLOAT, repetitiontype=OPTIONAL"`
+	DivRateTtm         *float32           `json:"div_rate_ttm" parquet:"name=DividendRateTTM, type=FLOAT, repetitiontype=OPTIONAL"`
+	LastDivTimestamp   *int64             `json:"last_div_date" parquet:"name=LastDividendDate, type=INT64, repetitiontype=OPTIONAL"`
+	DivPayTimestamp    *int64             `json:"div_pay_date" parquet:"name=DividendPayDate, type=INT64, repetitiontype=OPTIONAL"`
+	InceptionTimestamp *int64             `json:"inception_date" parquet:"name=InceptionDate, type=INT64, repetitiontype=OPTIONAL"`
+	Beta24             *float32           `json:"beta24" parquet:"name=Beta24, type=FLOAT, repetitiontype=OPTIONAL"`
+	Extras             map[string]float64 `json:"extras" parquet:"name=Extras, type=MAP, convertedtype=MAP, keytype=BYTE_ARRAY, keyconvertedtype=UTF8, valuetype=DOUBLE"`
+	MissingReasons     map[string]string  `json:"missingReasons" parquet:"name=MissingReasons, type=MAP, convertedtype=MAP, keytype=BYTE_ARRAY, keyconvertedtype=UTF8, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+}
+
+func (record *EtfRecord) EventDate() time.Time {
+	return record.Date
+}
+
+func (record *EtfRecord) Security() Ticker {
+	return Ticker{
+		CompanyName:    record.CompanyName,
+		TickerId:       record.TickerId,
+		Ticker:         record.Ticker,
+		CompositeFigi:  record.CompositeFigi,
+		EquityType:     record.Type,
+		Exchange:       record.Exchange,
+		FollowersCount: record.FollowersCount,
+	}
+}
+
+func (record *EtfRecord) setSecurity(ticker *Ticker, date, fetchedAt time.Time) {
+	record.DateStr = date.Format("2006-01-02")
+	record.Date = date
+	record.FetchedAt = fetchedAt.UnixMilli()
+	record.TickerId = ticker.TickerId
+	record.Ticker = normalizeTicker(ticker.Ticker)
+	record.CompanyName = ticker.CompanyName
+	record.Exchange = ticker.Exchange
+	record.Type = ticker.EquityType
+	record.FollowersCount = ticker.FollowersCount
+}
+
+func (record *EtfRecord) setCompositeFigi(compositeFigi string) {
+	record.CompositeFigi = compositeFigi
+}
+
+func (record *EtfRecord) setExtra(metricName string, val float64) {
+	if record.Extras == nil {
+		record.Extras = make(map[string]float64)
+	}
+	record.Extras[metricName] = val
+}
+
+func (record *EtfRecord) hasExtra(metricName string) bool {
+	_, ok := record.Extras[metricName]
+	return ok
+}
+
+func (record *EtfRecord) setMissing(metricName, reason string) {
+	if record.MissingReasons == nil {
+		record.MissingReasons = make(map[string]string)
+	}
+	record.MissingReasons[metricName] = reason
+}
+
+func (record *EtfRecord) clearMissing(metricName string) {
+	delete(record.MissingReasons, metricName)
+}
+
+func (record *EtfRecord) missingReason(metricName string) (string, bool) {
+	reason, ok := record.MissingReasons[metricName]
+	return reason, ok
+}
+
+func (record *EtfRecord) catalog() ([]byte, string) {
+	return defaultEtfCatalog, "catalog.etf_file"
+}
+
+func (record *EtfRecord) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("FundName", record.CompanyName)
+	e.Str("Ticker", record.Ticker)
+	e.Str("CompositeFigi", record.CompositeFigi)
+	e.Time("EventDate", record.Date)
+	if record.QuantRating != nil {
+		e.Float32("QuantRating", *record.QuantRating)
+	}
+	if record.Aum != nil {
+		e.Float64("Aum", *record.Aum)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+// Reasons recorded in the MissingReasons column when a metric has no value
+const (
+	ReasonNotMeaningful = "not_meaningful"
+	ReasonNotReturned   = "not_returned"
+	ReasonTypeMismatch  = "type_mismatch"
+)
+
+func ptr[T any](val T) *T {
+	return &val
+}
+
+// deref returns the value pointed to by val or the zero value if val is nil
+func deref[T any](val *T) T {
+	if val == nil {
+		var zero T
+		return zero
+	}
+	return *val
+}
+
+func (record *SeekingAlphaRecord) setMissing(metricName, reason string) {
+	if record.MissingReasons == nil {
+		record.MissingReasons = make(map[string]string)
+	}
+	record.MissingReasons[metricName] = reason
+}
+
+func (record *SeekingAlphaRecord) clearMissing(metricName string) {
+	delete(record.MissingReasons, metricName)
+}
+
+// markNotReturned records ReasonNotReturned for every metric in the catalog
+// that has no value and no other reason recorded
+func (catalog *Catalog) markNotReturned(record Record) {
+	for _, metric := range catalog.Metrics {
+		if metric.isSet(record) {
+			continue
+		}
+
+		if _, ok := record.missingReason(metric.Field); !ok {
+			record.setMissing(metric.Field, ReasonNotReturned)
+		}
+	}
+}
@@ -21,7 +21,7 @@ import (
 	"github.com/xitongsys/parquet-go/writer"
 )
 
-func SaveToParquet(records []*SeekingAlphaRecord, fn string) error {
+func SaveToParquet[R Record](records []R, fn string) error {
 	var err error
 
 	fh, err := local.NewLocalFileWriter(fn)
@@ -31,7 +31,7 @@ func SaveToParquet(records []*SeekingAlphaRecord, fn string) error {
 	}
 	defer fh.Close()
 
-	pw, err := writer.NewParquetWriter(fh, new(SeekingAlphaRecord), 4)
+	pw, err := writer.NewParquetWriter(fh, newRecord[R](), 4)
 	if err != nil {
 		log.Error().
 			Err(err).
@@ -45,10 +45,11 @@ func SaveToParquet(records []*SeekingAlphaRecord, fn string) error {
 
 	for _, r := range records {
 		if err = pw.Write(r); err != nil {
+			security := r.Security()
 			log.Error().
 				Err(err).
-				Str("EventDate", r.DateStr).Str("Ticker", r.Ticker).
-				Str("CompositeFigi", r.CompositeFigi).
+				Time("EventDate", r.EventDate()).Str("Ticker", security.Ticker).
+				Str("CompositeFigi", security.CompositeFigi).
 				Msg("Parquet write failed for record")
 		}
 	}
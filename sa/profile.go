@@ -0,0 +1,258 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	DEFAULT_PROFILE string = "default"
+
+	SECURITY_TYPE_STOCK string = "stock"
+	SECURITY_TYPE_ETF   string = "etf"
+)
+
+// ErrUniverseTooSmall is returned when the screener reports fewer matching
+// tickers than the MinCount of the profile
+var ErrUniverseTooSmall = errors.New("screener returned fewer tickers than the profile minimum")
+
+// ScreenerProfile describes a universe of securities selected with the Seeking
+// Alpha screener. Profiles are configured as [[screener.profiles]] tables and
+// each one is downloaded separately and written to its own output files.
+type ScreenerProfile struct {
+	Name string `mapstructure:"name"`
+
+	// Output is the prefix of the parquet and archive file names
+	Output string `mapstructure:"output"`
+
+	Type    string      `mapstructure:"type"`
+	PerPage int         `mapstructure:"per_page"`
+	Sort    string      `mapstructure:"sort"`
+	Filter  FilterGroup `mapstructure:"filter"`
+
+	// SkipDatabase writes the profile to parquet only
+	SkipDatabase bool `mapstructure:"skip_database"`
+
+	// MinCount fails the download when the screener reports fewer matching
+	// tickers, which usually means the session lost its premium access. The
+	// default profile uses 3000 when it is not set.
+	MinCount int `mapstructure:"min_count"`
+
+	// FailOnMissing fails the download when more than MissingTolerance
+	// screener tickers have no metrics
+	FailOnMissing    bool `mapstructure:"fail_on_missing"`
+	MissingTolerance int  `mapstructure:"missing_tolerance"`
+
+	// Tickers are the Seeking Alpha slugs of a subset run; when set the
+	// screener is skipped and the metrics of these slugs are fetched in
+	// chunks of PerPage
+	Tickers []string `mapstructure:"-"`
+}
+
+// DefaultProfile returns the profile of every stock with a quant rating
+func DefaultProfile() *ScreenerProfile {
+	return &ScreenerProfile{
+		Name:     DEFAULT_PROFILE,
+		Output:   "sa",
+		Type:     SECURITY_TYPE_STOCK,
+		PerPage:  100,
+		MinCount: 3000,
+		Filter: FilterGroup{
+			"quant_rating": FilterDef{
+				Gte:     ptr(1.0),
+				Lte:     ptr(5.0),
+				Exclude: false,
+			},
+		},
+	}
+}
+
+// LoadProfiles reads the screener profiles from the screener.profiles
+// configuration value. The default profile is always available and is
+// replaced by a configured profile with the same name.
+func LoadProfiles() ([]*ScreenerProfile, error) {
+	configured := make([]*ScreenerProfile, 0)
+	if err := viper.UnmarshalKey("screener.profiles", &configured); err != nil {
+		log.Error().Err(err).Msg("could not parse screener profiles")
+		return nil, err
+	}
+
+	profiles := []*ScreenerProfile{DefaultProfile()}
+	for _, profile := range configured {
+		if profile.Name == "" {
+			return nil, fmt.Errorf("screener profile is missing a name")
+		}
+
+		if profile.Name == DEFAULT_PROFILE {
+			profiles[0] = profile
+		} else {
+			profiles = append(profiles, profile)
+		}
+
+		if profile.Output == "" {
+			profile.Output = "sa-" + profile.Name
+			if profile.Name == DEFAULT_PROFILE {
+				profile.Output = DefaultProfile().Output
+			}
+		}
+
+		if profile.Type == "" {
+			profile.Type = SECURITY_TYPE_STOCK
+		}
+
+		if profile.Type != SECURITY_TYPE_STOCK && profile.Type != SECURITY_TYPE_ETF {
+			return nil, fmt.Errorf("screener profile %s has unsupported type %s", profile.Name, profile.Type)
+		}
+
+		if profile.PerPage <= 0 {
+			profile.PerPage = 100
+		}
+
+		// a replaced default profile keeps the built-in minimum unless it
+		// sets its own
+		if profile.Name == DEFAULT_PROFILE && profile.MinCount <= 0 {
+			profile.MinCount = DefaultProfile().MinCount
+		}
+	}
+
+	names := make(map[string]bool)
+	outputs := make(map[string]string)
+	for _, profile := range profiles {
+		if names[profile.Name] {
+			return nil, fmt.Errorf("screener profile %s is defined more than once", profile.Name)
+		}
+		names[profile.Name] = true
+
+		if other, ok := outputs[profile.Output]; ok {
+			return nil, fmt.Errorf("screener profiles %s and %s have the same output %s", other, profile.Name, profile.Output)
+		}
+		outputs[profile.Output] = profile.Name
+	}
+
+	return profiles, nil
+}
+
+// SelectProfiles returns the named screener profiles, or every profile if no
+// names are given
+func SelectProfiles(names []string) ([]*ScreenerProfile, error) {
+	profiles, err := LoadProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(names) == 0 {
+		return profiles, nil
+	}
+
+	byName := make(map[string]*ScreenerProfile, len(profiles))
+	for _, profile := range profiles {
+		byName[profile.Name] = profile
+	}
+
+	selected := make([]*ScreenerProfile, 0, len(names))
+	for _, name := range names {
+		profile, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown screener profile %s", name)
+		}
+		selected = append(selected, profile)
+	}
+
+	return selected, nil
+}
+
+// WithTickers returns a copy of the profile that fetches only tickers instead
+// of the screener results. Its output and checkpoint files are kept apart from
+// those of full runs.
+func (profile *ScreenerProfile) WithTickers(tickers []string) *ScreenerProfile {
+	subset := *profile
+	subset.Tickers = TickerSlugs(tickers)
+	subset.Output = profile.Output + "-tickers"
+	subset.MinCount = 0
+	return &subset
+}
+
+// IsSubset returns true if the profile fetches an explicit list of tickers
+func (profile *ScreenerProfile) IsSubset() bool {
+	return len(profile.Tickers) > 0
+}
+
+// IsEtf returns true if the profile selects ETFs, which are stored as
+// EtfRecord rather than SeekingAlphaRecord
+func (profile *ScreenerProfile) IsEtf() bool {
+	return profile.Type == SECURITY_TYPE_ETF
+}
+
+// Arguments returns the screener request body for pageNum
+func (profile *ScreenerProfile) Arguments(pageNum int) ScreenerArguments {
+	args := ScreenerArguments{
+		Filter:     profile.Filter,
+		Page:       pageNum,
+		PerPage:    profile.PerPage,
+		TotalCount: true,
+		Type:       profile.Type,
+	}
+
+	if profile.Sort != "" {
+		args.Sort = ptr(profile.Sort)
+	}
+
+	return args
+}
+
+// FileName returns the name of an output file of the profile for the given
+// market date, e.g. sa-20230102.parquet
+func (profile *ScreenerProfile) FileName(date time.Time, suffix string) string {
+	return fmt.Sprintf("%s-%s%s", profile.Output, date.Format("20060102"), suffix)
+}
+
+// CheckpointFile returns the checkpoint file of the profile derived from fn so
+// that profiles can be resumed independently
+func (profile *ScreenerProfile) CheckpointFile(fn string) string {
+	ext := filepath.Ext(fn)
+	name := profile.Name
+	if profile.IsSubset() {
+		name += "-tickers"
+	}
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(fn, ext), name, ext)
+}
+
+// ArchiveFile returns the raw response archive of the profile for date. It is
+// kept in the directory of the checkpoint file fn so that a resumed run can
+// append to it.
+func (profile *ScreenerProfile) ArchiveFile(fn string, date time.Time) string {
+	return filepath.Join(filepath.Dir(fn), profile.FileName(date, "-raw.tar.gz"))
+}
+
+func (profile *ScreenerProfile) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("Name", profile.Name)
+	e.Str("Output", profile.Output)
+	e.Str("Type", profile.Type)
+	e.Int("PerPage", profile.PerPage)
+	e.Str("Sort", profile.Sort)
+	e.Int("MinCount", profile.MinCount)
+	if profile.IsSubset() {
+		e.Int("NumTickers", len(profile.Tickers))
+	}
+}
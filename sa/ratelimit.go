@@ -0,0 +1,181 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// RateLimiter is a token bucket that paces requests to Seeking Alpha. The
+// rate is halved whenever a request is rate limited (429) or slower than
+// SlowLatency and is raised again, up to MaxRate, after RecoverAfter
+// consecutive fast successes.
+type RateLimiter struct {
+	MaxRate      float64
+	MinRate      float64
+	Burst        int
+	SlowLatency  time.Duration
+	RecoverAfter int
+
+	rate   float64
+	tokens float64
+	last   time.Time
+	streak int
+	stats  RateLimiterStats
+	mu     sync.Mutex
+}
+
+// RateLimiterStats summarizes how requests were paced during a run
+type RateLimiterStats struct {
+	Requests  int
+	Throttled time.Duration
+	Slowdowns int
+	Speedups  int
+	Started   time.Time
+}
+
+// NewRateLimiter creates a limiter that allows rps requests per second on
+// average with bursts of up to burst requests. A rate of 0 disables limiting.
+func NewRateLimiter(rps float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	now := time.Now()
+	return &RateLimiter{
+		MaxRate:      rps,
+		MinRate:      rps / 16,
+		Burst:        burst,
+		SlowLatency:  5 * time.Second,
+		RecoverAfter: 20,
+
+		rate:   rps,
+		tokens: float64(burst),
+		last:   now,
+		stats: RateLimiterStats{
+			Started: now,
+		},
+	}
+}
+
+// RateLimiterFromConfig creates a limiter from the ratelimit.* configuration values
+func RateLimiterFromConfig() *RateLimiter {
+	limiter := NewRateLimiter(viper.GetFloat64("ratelimit.rps"), viper.GetInt("ratelimit.burst"))
+
+	if minRate := viper.GetFloat64("ratelimit.min_rps"); minRate > 0 {
+		limiter.MinRate = math.Min(minRate, limiter.MaxRate)
+	}
+
+	if slowLatency := viper.GetDuration("ratelimit.slow_latency"); slowLatency > 0 {
+		limiter.SlowLatency = slowLatency
+	}
+
+	if recoverAfter := viper.GetInt("ratelimit.recover_after"); recoverAfter > 0 {
+		limiter.RecoverAfter = recoverAfter
+	}
+
+	return limiter
+}
+
+// Wait blocks until a request may be sent
+func (limiter *RateLimiter) Wait() {
+	limiter.mu.Lock()
+	limiter.stats.Requests++
+	if limiter.rate <= 0 {
+		limiter.mu.Unlock()
+		return
+	}
+
+	// refill the bucket for the time since the last request and reserve a
+	// token; a negative balance is the time the caller must wait
+	now := time.Now()
+	limiter.tokens = math.Min(float64(limiter.Burst), limiter.tokens+now.Sub(limiter.last).Seconds()*limiter.rate)
+	limiter.last = now
+	limiter.tokens--
+
+	var delay time.Duration
+	if limiter.tokens < 0 {
+		delay = time.Duration(-limiter.tokens / limiter.rate * float64(time.Second))
+		limiter.stats.Throttled += delay
+	}
+	limiter.mu.Unlock()
+
+	time.Sleep(delay)
+}
+
+// Observe adjusts the rate based on the outcome of a request
+func (limiter *RateLimiter) Observe(latency time.Duration, err error) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	if limiter.rate <= 0 {
+		return
+	}
+
+	var statusErr *StatusError
+	rateLimited := errors.As(err, &statusErr) && statusErr.Status == http.StatusTooManyRequests
+
+	switch {
+	case rateLimited || latency > limiter.SlowLatency:
+		limiter.streak = 0
+		if limiter.rate > limiter.MinRate {
+			limiter.rate = math.Max(limiter.MinRate, limiter.rate/2)
+			limiter.stats.Slowdowns++
+			log.Warn().Bool("RateLimited", rateLimited).Dur("Latency", latency).Float64("Rate", limiter.rate).Msg("slowing down requests")
+		}
+	case err == nil:
+		limiter.streak++
+		if limiter.streak >= limiter.RecoverAfter && limiter.rate < limiter.MaxRate {
+			limiter.streak = 0
+			limiter.rate = math.Min(limiter.MaxRate, limiter.rate*1.5)
+			limiter.stats.Speedups++
+			log.Info().Float64("Rate", limiter.rate).Msg("speeding up requests")
+		}
+	}
+}
+
+// Stats returns the pacing statistics collected so far
+func (limiter *RateLimiter) Stats() RateLimiterStats {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	return limiter.stats
+}
+
+// LogStats writes the effective request rate and time spent throttled to the log
+func (limiter *RateLimiter) LogStats() {
+	stats := limiter.Stats()
+	elapsed := time.Since(stats.Started)
+
+	effectiveRate := 0.0
+	if elapsed > 0 {
+		effectiveRate = float64(stats.Requests) / elapsed.Seconds()
+	}
+
+	log.Info().
+		Int("Requests", stats.Requests).
+		Dur("Elapsed", elapsed).
+		Float64("EffectiveRate", effectiveRate).
+		Dur("Throttled", stats.Throttled).
+		Int("Slowdowns", stats.Slowdowns).
+		Int("Speedups", stats.Speedups).
+		Msg("request rate statistics")
+}
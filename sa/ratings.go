@@ -47,11 +47,12 @@ func LoadRatings(ratingsDir string, limit int) []*SeekingAlphaRecord {
 	return ratings
 }
 
-func isValidExchange(record *SeekingAlphaRecord) bool {
-	return (record.Exchange != "OTCQX" &&
-		record.Exchange != "OTCQB" &&
-		record.Exchange != "OTC Markets" &&
-		record.Exchange != "Grey Market" &&
-		record.Exchange != "Pink No Info" &&
-		record.Exchange != "Pink Current Info")
+func isValidExchange(record Record) bool {
+	exchange := record.Security().Exchange
+	return (exchange != "OTCQX" &&
+		exchange != "OTCQB" &&
+		exchange != "OTC Markets" &&
+		exchange != "Grey Market" &&
+		exchange != "Pink No Info" &&
+		exchange != "Pink Current Info")
 }
@@ -0,0 +1,104 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxLoggedMissing limits how many missing tickers are listed in the log
+const maxLoggedMissing = 50
+
+// Reconciliation compares the tickers the screener returned with the records
+// that were built from the metrics responses
+type Reconciliation struct {
+	Profile string
+
+	// ScreenerCount is the number of matching tickers reported by the screener
+	ScreenerCount int
+
+	// NumSlugs is the number of tickers returned across all screener pages
+	NumSlugs   int
+	NumRecords int
+
+	// Missing lists the screener tickers that have no record
+	Missing []string
+}
+
+// reconcile checks that every slug returned by the screener has a record
+func reconcile[R Record](profile string, screenerCount int, slugs []string, records map[string]R) *Reconciliation {
+	tickers := make(map[string]bool, len(records))
+	for _, record := range records {
+		tickers[record.Security().Ticker] = true
+	}
+
+	result := &Reconciliation{
+		Profile:       profile,
+		ScreenerCount: screenerCount,
+		NumRecords:    len(records),
+		Missing:       make([]string, 0),
+	}
+
+	// a ticker can move between pages while the screener is paged through
+	seen := make(map[string]bool, len(slugs))
+	for _, slug := range slugs {
+		ticker := normalizeTicker(slug)
+		if seen[ticker] {
+			continue
+		}
+		seen[ticker] = true
+
+		if !tickers[ticker] {
+			result.Missing = append(result.Missing, ticker)
+		}
+	}
+	result.NumSlugs = len(seen)
+
+	sort.Strings(result.Missing)
+	return result
+}
+
+// Log writes the reconciliation report
+func (result *Reconciliation) Log() {
+	if result.NumSlugs != result.ScreenerCount {
+		log.Warn().Str("Profile", result.Profile).Int("ScreenerCount", result.ScreenerCount).Int("NumSlugs", result.NumSlugs).Msg("number of tickers returned by screener does not match its reported count")
+	}
+
+	if len(result.Missing) == 0 {
+		log.Info().Str("Profile", result.Profile).Int("NumSlugs", result.NumSlugs).Int("NumRecords", result.NumRecords).Msg("every screener ticker has metrics")
+		return
+	}
+
+	logged := result.Missing
+	if len(logged) > maxLoggedMissing {
+		logged = logged[:maxLoggedMissing]
+	}
+
+	log.Warn().Str("Profile", result.Profile).Int("NumSlugs", result.NumSlugs).Int("NumRecords", result.NumRecords).Int("NumMissing", len(result.Missing)).Strs("Missing", logged).Msg("screener tickers returned no metrics")
+}
+
+// Check returns an error if more screener tickers are missing metrics than the
+// profile tolerates. It always succeeds when the profile does not fail on
+// missing tickers.
+func (result *Reconciliation) Check(profile *ScreenerProfile) error {
+	if !profile.FailOnMissing || len(result.Missing) <= profile.MissingTolerance {
+		return nil
+	}
+
+	return fmt.Errorf("%d screener tickers returned no metrics (tolerance %d)", len(result.Missing), profile.MissingTolerance)
+}
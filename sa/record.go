@@ -0,0 +1,125 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Record is a row that Seeking Alpha metrics are parsed into. It is
+// implemented by *SeekingAlphaRecord for stocks and *EtfRecord for ETFs; the
+// metric columns of each are declared by their own catalog.
+type Record interface {
+	zerolog.LogObjectMarshaler
+
+	// EventDate is the market date the metrics were fetched for
+	EventDate() time.Time
+
+	// Security returns the identity of the security the record describes
+	Security() Ticker
+
+	// setSecurity stamps the record with the security, the trading session
+	// date and the wall-clock time its metrics were fetched
+	setSecurity(ticker *Ticker, date, fetchedAt time.Time)
+	setCompositeFigi(compositeFigi string)
+	setExtra(metricName string, val float64)
+	hasExtra(metricName string) bool
+	setMissing(metricName, reason string)
+	clearMissing(metricName string)
+	missingReason(metricName string) (string, bool)
+
+	// catalog returns the embedded metric catalog of the record type and the
+	// configuration key naming its override file
+	catalog() ([]byte, string)
+}
+
+// newRecord allocates an empty record of type R
+func newRecord[R Record]() R {
+	var record R
+	return reflect.New(reflect.TypeOf(record).Elem()).Interface().(R)
+}
+
+// normalizeTicker converts a Seeking Alpha slug (e.g. brk.b) into the ticker
+// format used by the assets table (e.g. BRK/B)
+func normalizeTicker(slug string) string {
+	return strings.ReplaceAll(strings.ToUpper(slug), ".", "/")
+}
+
+func (record *SeekingAlphaRecord) EventDate() time.Time {
+	return record.Date
+}
+
+func (record *SeekingAlphaRecord) Security() Ticker {
+	return Ticker{
+		CompanyName:    record.CompanyName,
+		TickerId:       record.TickerId,
+		Ticker:         record.Ticker,
+		CompositeFigi:  record.CompositeFigi,
+		EquityType:     record.Type,
+		Exchange:       record.Exchange,
+		FollowersCount: record.FollowersCount,
+		IsBdc:          record.IsBdc,
+		IsDefunct:      record.IsDefunct,
+		IsReit:         record.IsReit,
+		Sector:         record.Sector,
+		Industry:       record.Industry,
+	}
+}
+
+func (record *SeekingAlphaRecord) setSecurity(ticker *Ticker, date, fetchedAt time.Time) {
+	record.DateStr = date.Format("2006-01-02")
+	record.Date = date
+	record.FetchedAt = fetchedAt.UnixMilli()
+	record.TickerId = ticker.TickerId
+	record.Ticker = normalizeTicker(ticker.Ticker)
+	record.CompanyName = ticker.CompanyName
+	record.Exchange = ticker.Exchange
+	record.Type = ticker.EquityType
+	record.FollowersCount = ticker.FollowersCount
+	record.IsReit = ticker.IsReit
+	record.IsBdc = ticker.IsBdc
+	record.IsDefunct = ticker.IsDefunct
+	record.Sector = ticker.Sector
+	record.Industry = ticker.Industry
+}
+
+func (record *SeekingAlphaRecord) setCompositeFigi(compositeFigi string) {
+	record.CompositeFigi = compositeFigi
+}
+
+func (record *SeekingAlphaRecord) setExtra(metricName string, val float64) {
+	if record.Extras == nil {
+		record.Extras = make(map[string]float64)
+	}
+	record.Extras[metricName] = val
+}
+
+func (record *SeekingAlphaRecord) hasExtra(metricName string) bool {
+	_, ok := record.Extras[metricName]
+	return ok
+}
+
+func (record *SeekingAlphaRecord) missingReason(metricName string) (string, bool) {
+	reason, ok := record.MissingReasons[metricName]
+	return reason, ok
+}
+
+func (record *SeekingAlphaRecord) catalog() ([]byte, string) {
+	return defaultCatalog, "catalog.file"
+}
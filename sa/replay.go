@@ -0,0 +1,146 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ReadArchive loads the manifest and every response stored in a raw response
+// archive created by NewArchive
+func ReadArchive(fn string) (*ArchiveManifest, map[string][]byte, error) {
+	fh, err := os.Open(fn)
+	if err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("could not open raw response archive")
+		return nil, nil, err
+	}
+	defer fh.Close()
+
+	gz, err := gzip.NewReader(fh)
+	if err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("raw response archive is not gzip compressed")
+		return nil, nil, err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	contents := make(map[string][]byte)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			log.Error().Err(err).Str("FileName", fn).Msg("could not read raw response archive")
+			return nil, nil, err
+		}
+
+		body, err := io.ReadAll(tr)
+		if err != nil {
+			log.Error().Err(err).Str("Entry", hdr.Name).Msg("could not read archive entry")
+			return nil, nil, err
+		}
+		contents[hdr.Name] = body
+	}
+
+	data, ok := contents[ARCHIVE_MANIFEST_NAME]
+	if !ok {
+		return nil, nil, fmt.Errorf("%s has no %s", fn, ARCHIVE_MANIFEST_NAME)
+	}
+
+	var manifest ArchiveManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		log.Error().Err(err).Str("FileName", fn).Msg("could not parse archive manifest")
+		return nil, nil, err
+	}
+
+	return &manifest, contents, nil
+}
+
+// Replay rebuilds the records of a run from the manifest and contents of a
+// raw response archive read with ReadArchive, without contacting Seeking
+// Alpha. Responses are parsed with the current metric catalog of R so
+// archives can be re-processed after parser fixes. Records are stamped with
+// date, which is usually the archive date passed through TradingSession.
+func Replay[R Record](manifest *ArchiveManifest, contents map[string][]byte, date time.Time) ([]R, error) {
+	catalog, err := LoadCatalog[R]()
+	if err != nil {
+		log.Error().Err(err).Msg("could not load metric catalog")
+		return []R{}, err
+	}
+
+	driftSeverity, err := DriftSeverityFromConfig()
+	if err != nil {
+		log.Error().Err(err).Msg("invalid drift severity")
+		return []R{}, err
+	}
+	drift := NewDriftReport(manifest.Profile, catalog)
+
+	log.Info().Time("Date", date).Str("Profile", manifest.Profile).Int("NumEntries", len(manifest.Entries)).Str("Version", manifest.Version).Msg("replaying raw response archive")
+
+	// only the final attempt of a retried request is parsed
+	lastAttempt := make(map[string]int)
+	for _, entry := range manifest.Entries {
+		lastAttempt[fmt.Sprintf("%d/%s", entry.Page, entry.Endpoint)] = entry.Attempt
+	}
+
+	consolidatedMetrics := make(map[string]R)
+	for _, entry := range manifest.Entries {
+		if entry.Endpoint == ARCHIVE_SCREENER_ENDPOINT {
+			continue
+		}
+
+		if entry.Attempt != lastAttempt[fmt.Sprintf("%d/%s", entry.Page, entry.Endpoint)] {
+			continue
+		}
+
+		if entry.Status != 200 {
+			log.Warn().Str("Entry", entry.Name).Int("Status", entry.Status).Msg("skipping response with invalid status")
+			continue
+		}
+
+		body, ok := contents[entry.Name]
+		if !ok {
+			return []R{}, fmt.Errorf("archive entry %s is missing", entry.Name)
+		}
+
+		var metrics MetricsResponse
+		if err := json.Unmarshal(body, &metrics); err != nil {
+			log.Warn().Err(err).Str("Entry", entry.Name).Msg("skipping response that is not valid JSON")
+			drift.add(DRIFT_UNDECODABLE, entry.Endpoint, entry)
+			continue
+		}
+
+		parseMetrics(metrics, consolidatedMetrics, catalog, drift, date, entry.FetchedAt)
+	}
+
+	drift.Log(driftSeverity)
+	if err := drift.Check(driftSeverity); err != nil {
+		log.Error().Err(err).Msg("schema drift check failed")
+		return []R{}, err
+	}
+
+	log.Info().Int("NumRecords", len(consolidatedMetrics)).Msg("loaded seeking alpha record")
+	return consolidateRecords(consolidatedMetrics, catalog), nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+var (
+	// ErrBlocked is returned when Seeking Alpha responds with a PerimeterX
+	// block or challenge page instead of data
+	ErrBlocked = errors.New("request blocked by Seeking Alpha")
+
+	// ErrSchemaMismatch is returned when a response is valid JSON that does
+	// not match the expected schema
+	ErrSchemaMismatch = errors.New("response does not match expected schema")
+
+	// ErrTruncated is returned when a response body is not complete JSON
+	ErrTruncated = errors.New("response body is truncated")
+)
+
+// blockPageMarkers are found in the PerimeterX block and challenge pages
+var blockPageMarkers = [][]byte{
+	[]byte("px-captcha"),
+	[]byte("_pxCaptcha"),
+	[]byte("perimeterx"),
+	[]byte("PerimeterX"),
+	[]byte("Access to this page has been denied"),
+}
+
+// isBlockPage returns true if body is a PerimeterX block or challenge page
+func isBlockPage(body []byte) bool {
+	for _, marker := range blockPageMarkers {
+		if bytes.Contains(body, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeResponse deserializes a response body into v and classifies any
+// failure as ErrBlocked, ErrSchemaMismatch or ErrTruncated
+func decodeResponse(body []byte, v any) error {
+	err := json.Unmarshal(body, v)
+	if err == nil {
+		return nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case isChallenge(http.StatusOK, nil, body):
+		return ErrBlocked
+	case errors.As(err, &typeErr):
+		return errors.Join(ErrSchemaMismatch, err)
+	default:
+		return errors.Join(ErrTruncated, err)
+	}
+}
+
+// isRetryable returns true if err is a transient failure that may succeed when
+// the request is repeated
+func isRetryable(err error) bool {
+	if errors.Is(err, ErrBlocked) || errors.Is(err, ErrSchemaMismatch) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch {
+		case isChallenge(statusErr.Status, statusErr.Header, statusErr.Body):
+			return false
+		case statusErr.Status == http.StatusTooManyRequests, statusErr.Status == http.StatusRequestTimeout:
+			return true
+		case statusErr.Status >= 500:
+			return true
+		default:
+			// 401, 403 and other client errors will not succeed on retry
+			return false
+		}
+	}
+
+	// timeouts, truncated bodies and connection failures
+	return true
+}
+
+// classifyBlock converts a status error carrying a block page into ErrBlocked
+func classifyBlock(err error) error {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && isChallenge(statusErr.Status, statusErr.Header, statusErr.Body) {
+		return errors.Join(ErrBlocked, err)
+	}
+	return err
+}
+
+// RetryPolicy controls how failed requests are repeated
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// RetryPolicyFromConfig reads the retry policy from the retry.* configuration values
+func RetryPolicyFromConfig() RetryPolicy {
+	policy := RetryPolicy{
+		MaxAttempts:    viper.GetInt("retry.max_attempts"),
+		InitialBackoff: viper.GetDuration("retry.initial_backoff"),
+		MaxBackoff:     viper.GetDuration("retry.max_backoff"),
+	}
+
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+
+	return policy
+}
+
+// backoff returns the jittered delay before the given retry attempt
+func (policy RetryPolicy) backoff(attempt int) time.Duration {
+	delay := float64(policy.InitialBackoff) * math.Pow(2, float64(attempt-1))
+	if policy.MaxBackoff > 0 && delay > float64(policy.MaxBackoff) {
+		delay = float64(policy.MaxBackoff)
+	}
+
+	// use between 50% and 100% of the delay so concurrent retries spread out
+	return time.Duration(delay * (0.5 + rand.Float64()/2))
+}
+
+// Do calls fn until it succeeds, returns a fatal error or the maximum number
+// of attempts is reached. Every attempt is logged with the page number and
+// endpoint.
+func (policy RetryPolicy) Do(pageNum int, endpoint string, fn func() error) error {
+	var err error
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		start := time.Now()
+		err = fn()
+		if err == nil {
+			log.Debug().Int("PageNum", pageNum).Str("Endpoint", endpoint).Int("Attempt", attempt).Dur("Elapsed", time.Since(start)).Msg("request succeeded")
+			return nil
+		}
+
+		if !isRetryable(err) {
+			log.Error().Err(err).Int("PageNum", pageNum).Str("Endpoint", endpoint).Int("Attempt", attempt).Msg("request failed with fatal error")
+			return err
+		}
+
+		if attempt == policy.MaxAttempts {
+			break
+		}
+
+		delay := policy.backoff(attempt)
+		log.Warn().Err(err).Int("PageNum", pageNum).Str("Endpoint", endpoint).Int("Attempt", attempt).Dur("Backoff", delay).Msg("request failed; retrying")
+		time.Sleep(delay)
+	}
+
+	log.Error().Err(err).Int("PageNum", pageNum).Str("Endpoint", endpoint).Int("MaxAttempts", policy.MaxAttempts).Msg("request failed after all attempts")
+	return err
+}
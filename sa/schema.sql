@@ -0,0 +1,33 @@
+-- Columns added to the seeking_alpha table after the initial schema. Every
+-- statement is idempotent so the file is applied before each save.
+
+-- dividend grades and metrics
+ALTER TABLE seeking_alpha
+    ADD COLUMN IF NOT EXISTS div_safety_grade real,
+    ADD COLUMN IF NOT EXISTS div_growth_grade real,
+    ADD COLUMN IF NOT EXISTS div_yield_grade real,
+    ADD COLUMN IF NOT EXISTS div_consistency_grade real,
+    ADD COLUMN IF NOT EXISTS last_div_date date,
+    ADD COLUMN IF NOT EXISTS div_pay_date date,
+    ADD COLUMN IF NOT EXISTS dividend_yield real,
+    ADD COLUMN IF NOT EXISTS div_yield_fwd real,
+    ADD COLUMN IF NOT EXISTS div_yield_4y real,
+    ADD COLUMN IF NOT EXISTS div_rate_ttm double precision,
+    ADD COLUMN IF NOT EXISTS div_rate_fwd double precision,
+    ADD COLUMN IF NOT EXISTS payout_ratio real,
+    ADD COLUMN IF NOT EXISTS payout_ratio_4y real,
+    ADD COLUMN IF NOT EXISTS div_grow_rate_3y real,
+    ADD COLUMN IF NOT EXISTS div_grow_rate_5y real,
+    ADD COLUMN IF NOT EXISTS dividend_growth real;
+
+-- security classification
+ALTER TABLE seeking_alpha
+    ADD COLUMN IF NOT EXISTS is_reit boolean,
+    ADD COLUMN IF NOT EXISTS is_bdc boolean,
+    ADD COLUMN IF NOT EXISTS is_defunct boolean,
+    ADD COLUMN IF NOT EXISTS sector text,
+    ADD COLUMN IF NOT EXISTS industry text;
+
+-- earnings
+ALTER TABLE seeking_alpha
+    ADD COLUMN IF NOT EXISTS earning_announce_date date;
@@ -0,0 +1,24 @@
+-- Table holding the ETF ratings saved by SaveEtfsToDB. The statement is
+-- idempotent so the file is applied before each save.
+CREATE TABLE IF NOT EXISTS seeking_alpha_etf (
+    ticker text NOT NULL,
+    composite_figi text NOT NULL,
+    event_date date NOT NULL,
+    quant_rating real,
+    authors_rating real,
+    momentum_grade real,
+    expenses_grade real,
+    dividends_grade real,
+    risk_grade real,
+    liquidity_grade real,
+    aum_mil double precision,
+    expense_ratio real,
+    dividend_yield real,
+    div_yield_fwd real,
+    div_rate_ttm real,
+    last_div_date date,
+    div_pay_date date,
+    inception_date date,
+    beta_24m real,
+    CONSTRAINT seeking_alpha_etf_pkey PRIMARY KEY (composite_figi, event_date)
+);
@@ -0,0 +1,285 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/penny-vault/import-sa-quant-rank/common"
+	"github.com/playwright-community/playwright-go"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// ENTITLEMENT_TICKER is the ticker whose quant rating is requested to check
+// that the session has premium access
+const ENTITLEMENT_TICKER string = "aapl"
+
+// Selectors of the Seeking Alpha sign in form
+const (
+	loginEmailSelector    string = `input[name="email"]`
+	loginPasswordSelector string = `input[name="password"]`
+	loginSubmitSelector   string = `button[type="submit"]`
+)
+
+var (
+	// ErrSessionExpired is returned when Seeking Alpha no longer accepts the
+	// session saved in the state file
+	ErrSessionExpired = errors.New("seeking alpha session expired")
+
+	// ErrNotEntitled is returned when the session does not have the premium
+	// access needed for quant ratings
+	ErrNotEntitled = errors.New("seeking alpha session is not entitled to quant ratings")
+
+	// ErrNoCredentials is returned by Login when no username or password is
+	// configured
+	ErrNoCredentials = errors.New("seeking alpha credentials are not configured")
+)
+
+// Reloader is implemented by transports that can pick up a new session from
+// the state file after logging in again
+type Reloader interface {
+	Reload() error
+}
+
+// Relogger is implemented by transports that use several accounts; Relogin
+// logs in to the account whose session expired
+type Relogger interface {
+	Relogin() error
+}
+
+// entitlementRequest requests the quant rating, which is only returned to
+// premium subscribers
+func entitlementRequest() *MetricRequest {
+	return &MetricRequest{
+		Group: "entitlement",
+		Url:   fmt.Sprintf("%s/metrics?filter[fields]=quant_rating&filter[slugs]=", API_BASE_URL),
+	}
+}
+
+// isEntitled returns true if the body of an entitlement request contains a
+// meaningful quant rating
+func isEntitled(body []byte) bool {
+	var metrics MetricsResponse
+	if err := json.Unmarshal(body, &metrics); err != nil {
+		return false
+	}
+
+	for _, item := range metrics.Data {
+		if meaningful, ok := item.Attributes["meaningful"].(bool); !ok || !meaningful {
+			continue
+		}
+		if _, ok := item.Attributes["value"].(float64); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CookieExpiry is the expiry time of a cookie in the state file
+type CookieExpiry struct {
+	Name   string
+	Domain string
+
+	// Expires is the zero time for cookies that last for the browser session
+	Expires time.Time
+}
+
+// Expired returns true if the cookie expired before now
+func (cookie CookieExpiry) Expired(now time.Time) bool {
+	return !cookie.Expires.IsZero() && cookie.Expires.Before(now)
+}
+
+// SessionCookies returns the expiry of every Seeking Alpha cookie in the state
+// file, earliest first
+func SessionCookies(state *common.SessionState) []CookieExpiry {
+	cookies := make([]CookieExpiry, 0, len(state.Cookies))
+	for _, cookie := range state.Cookies {
+		if !strings.HasSuffix(cookie.Domain, "seekingalpha.com") {
+			continue
+		}
+
+		expiry := CookieExpiry{
+			Name:   cookie.Name,
+			Domain: cookie.Domain,
+		}
+		if cookie.Expires > 0 {
+			expiry.Expires = time.Unix(int64(cookie.Expires), 0)
+		}
+		cookies = append(cookies, expiry)
+	}
+
+	sort.SliceStable(cookies, func(i, j int) bool {
+		if cookies[i].Expires.IsZero() != cookies[j].Expires.IsZero() {
+			return cookies[j].Expires.IsZero()
+		}
+		return cookies[i].Expires.Before(cookies[j].Expires)
+	})
+
+	return cookies
+}
+
+// CheckEntitlement requests the quant rating of ENTITLEMENT_TICKER with
+// transport and returns ErrNotEntitled if it is not meaningful
+func CheckEntitlement(transport Transport) error {
+	body, err := transport.Metrics(entitlementRequest(), []string{ENTITLEMENT_TICKER})
+	if err != nil {
+		err = classifySession(classifyBlock(err))
+		log.Error().Err(err).Msg("entitlement check failed")
+		return err
+	}
+
+	if !isEntitled(body) {
+		if isChallenge(http.StatusOK, nil, body) {
+			return ErrBlocked
+		}
+		log.Error().Str("Ticker", ENTITLEMENT_TICKER).Msg("quant rating is not meaningful; session does not have premium access")
+		return ErrNotEntitled
+	}
+
+	log.Info().Str("Ticker", ENTITLEMENT_TICKER).Msg("session is entitled to quant ratings")
+	return nil
+}
+
+// classifySession marks a 401 response as an expired session
+func classifySession(err error) error {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.Status == http.StatusUnauthorized && !errors.Is(err, ErrBlocked) {
+		return errors.Join(ErrSessionExpired, err)
+	}
+	return err
+}
+
+// Login signs in to Seeking Alpha in a headless browser with the credentials
+// of account and saves the session to its state file once an authenticated
+// API request succeeds
+func Login(account *Account) error {
+	if !account.HasCredentials() {
+		return ErrNoCredentials
+	}
+
+	log.Info().Str("Account", account.Name).Str("Username", account.Username).Msg("logging in to seeking alpha")
+
+	page, context, browser, pw, err := common.StartPlaywright(true, account.StateFile, account.Proxy)
+	if err != nil {
+		return err
+	}
+
+	err = submitLogin(page, account.Username, account.Password)
+	if err == nil {
+		err = WaitForSession(page, viper.GetDuration("login.timeout"))
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("login failed")
+		// keep the previous session rather than saving a failed one
+		common.ClosePlaywright(browser, pw)
+		return err
+	}
+
+	log.Info().Msg("login succeeded")
+	common.StopPlaywright(page, context, browser, pw, account.StateFile)
+	return nil
+}
+
+// submitLogin fills in and submits the sign in form
+func submitLogin(page playwright.Page, username, password string) error {
+	if _, err := page.Goto(LOGIN_PAGE_URL, playwright.PageGotoOptions{
+		WaitUntil: playwright.WaitUntilStateNetworkidle,
+	}); err != nil {
+		log.Error().Err(err).Msg("could not load login page")
+		return err
+	}
+
+	if err := page.Fill(loginEmailSelector, username); err != nil {
+		log.Error().Err(err).Msg("could not fill in email")
+		return err
+	}
+
+	if err := page.Fill(loginPasswordSelector, password); err != nil {
+		log.Error().Err(err).Msg("could not fill in password")
+		return err
+	}
+
+	if err := page.Click(loginSubmitSelector); err != nil {
+		log.Error().Err(err).Msg("could not submit login form")
+		return err
+	}
+
+	return nil
+}
+
+// WaitForSession polls the entitlement request from page until it returns a
+// meaningful quant rating or timeout elapses
+func WaitForSession(page playwright.Page, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = 2 * time.Minute
+	}
+
+	url := metricsUrl(entitlementRequest(), []string{ENTITLEMENT_TICKER})
+	deadline := time.Now().Add(timeout)
+	for {
+		result, err := page.Evaluate(`async (url) => {
+            const resp = await fetch(url);
+            return await resp.text();
+        }`, url)
+		if err != nil {
+			log.Debug().Err(err).Msg("session check failed")
+		} else if body, ok := result.(string); ok && isEntitled([]byte(body)) {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w: no authenticated response within %s", ErrSessionExpired, timeout)
+		}
+		page.WaitForTimeout(2000)
+	}
+}
+
+// relogin logs in again after the session expired and reloads the transport
+// so that it uses the new session
+func relogin(transport Transport) error {
+	if !viper.GetBool("login.auto") {
+		return errors.New("automatic login is disabled")
+	}
+
+	if relogger, ok := transport.(Relogger); ok {
+		return relogger.Relogin()
+	}
+
+	return loginAndReload(DefaultAccount(), transport)
+}
+
+// loginAndReload logs in to account and reloads transport, which must use the
+// state file of account
+func loginAndReload(account *Account, transport Transport) error {
+	if err := Login(account); err != nil {
+		return err
+	}
+
+	if reloader, ok := transport.(Reloader); ok {
+		return reloader.Reload()
+	}
+
+	log.Warn().Msg("transport cannot reload the session")
+	return nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// tickerSlug converts a ticker in the format used by the assets table (e.g.
+// BRK/B) into a Seeking Alpha slug (e.g. brk.b); it is the inverse of
+// normalizeTicker
+func tickerSlug(ticker string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(ticker)), "/", ".")
+}
+
+// TickerSlugs converts a list of tickers into unique Seeking Alpha slugs in
+// the order they were given. Blank entries are ignored.
+func TickerSlugs(tickers []string) []string {
+	slugs := make([]string, 0, len(tickers))
+	seen := make(map[string]bool, len(tickers))
+	for _, ticker := range tickers {
+		slug := tickerSlug(ticker)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		slugs = append(slugs, slug)
+	}
+	return slugs
+}
+
+// ReadTickersFile reads a list of tickers separated by commas or new lines.
+// Everything after a # on a line is a comment.
+func ReadTickersFile(fn string) ([]string, error) {
+	fh, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	tickers := make([]string, 0)
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		tickers = append(tickers, strings.Split(line, ",")...)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return tickers, nil
+}
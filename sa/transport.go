@@ -0,0 +1,123 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// Transport fetches raw responses from the Seeking Alpha API
+type Transport interface {
+	// Screener POSTs the screener arguments for page pageNum and returns the
+	// raw response body
+	Screener(pageNum int, args []byte) ([]byte, error)
+
+	// Metrics GETs the metrics of request for the given ticker slugs and
+	// returns the raw response body
+	Metrics(request *MetricRequest, slugs []string) ([]byte, error)
+
+	// Close releases any resources held by the transport
+	Close() error
+}
+
+// StatusError is returned by a Transport when Seeking Alpha responds with a
+// status other than 200 or with an HTML page instead of JSON
+type StatusError struct {
+	Url    string
+	Status int
+	Body   []byte
+	Header http.Header
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s returned status %d", e.Url, e.Status)
+}
+
+// NewTransport creates the transport used to call the Seeking Alpha API. When
+// several accounts are configured in seekingalpha.accounts requests rotate
+// between them as set by seekingalpha.rotation; otherwise the transport of the
+// single account is returned.
+func NewTransport() (Transport, error) {
+	if !viper.IsSet("seekingalpha.accounts") {
+		return NewAccountTransport(DefaultAccount())
+	}
+
+	accounts, err := LoadAccounts()
+	if err != nil {
+		return nil, err
+	}
+
+	rotating, err := NewRotatingTransport(accounts, viper.GetString("seekingalpha.rotation"), NewAccountTransport)
+	if err != nil {
+		return nil, err
+	}
+
+	if usageFile := viper.GetString("seekingalpha.usage_file"); usageFile != "" {
+		usage, err := ReadAccountUsage(usageFile)
+		if err != nil {
+			log.Error().Err(err).Str("UsageFile", usageFile).Msg("could not read account usage")
+			return nil, err
+		}
+		rotating.UsageFile = usageFile
+		rotating.StartWithLeastUsed(usage)
+	}
+
+	log.Info().Int("NumAccounts", len(accounts)).Str("Rotation", rotating.Mode).Msg("rotating between seeking alpha accounts")
+	return rotating, nil
+}
+
+// NewAccountTransport creates the transport named by the transport
+// configuration value for account; one of playwright (the default), http, or
+// auto which uses http and falls back to playwright when it is blocked
+func NewAccountTransport(account *Account) (Transport, error) {
+	name := viper.GetString("transport")
+	log.Info().Str("Transport", name).Str("Account", account.Name).Msg("creating transport")
+
+	switch name {
+	case "", "playwright":
+		return NewPlaywrightTransport(account)
+	case "http":
+		return NewHttpTransport(account)
+	case "auto":
+		return NewHttpFallbackTransport(account)
+	default:
+		return nil, fmt.Errorf("unknown transport %s", name)
+	}
+}
+
+// metricsUrl returns the URL to fetch the metrics of request for the given slugs
+func metricsUrl(request *MetricRequest, slugs []string) string {
+	return fmt.Sprintf("%s%s", request.Url, strings.Join(slugs, "%2C"))
+}
+
+// responseStatus returns the status and body of a transport response; errors
+// other than a StatusError have a status of 0
+func responseStatus(body []byte, err error) (int, []byte) {
+	var statusErr *StatusError
+	switch {
+	case err == nil:
+		return 200, body
+	case errors.As(err, &statusErr):
+		return statusErr.Status, statusErr.Body
+	default:
+		return 0, body
+	}
+}
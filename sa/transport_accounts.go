@@ -0,0 +1,358 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrAccountsBlocked is returned when every account has been blocked
+var ErrAccountsBlocked = errors.New("every seeking alpha account is blocked")
+
+// RotatingTransport spreads requests across several Seeking Alpha accounts,
+// each with its own transport. In ROTATION_PAGE mode every screener page
+// switches to the next account; in ROTATION_FAILOVER mode an account is used
+// until it is blocked. Either way a blocked account is skipped for the rest of
+// the run and the blocked request is repeated with the next account.
+type RotatingTransport struct {
+	Mode string
+
+	// UsageFile accumulates the request counts of each account across runs
+	// when it is set
+	UsageFile string
+
+	slots        []*accountSlot
+	newTransport func(*Account) (Transport, error)
+
+	// start is the account the rotation starts with and current the account
+	// requests are sent with
+	start   int
+	current int
+
+	mu sync.Mutex
+}
+
+// accountSlot is an account, its transport, which is created when the
+// account is first used, and its request counts for the run
+type accountSlot struct {
+	account   *Account
+	transport Transport
+	blocked   bool
+	usage     AccountUsage
+}
+
+// NewRotatingTransport creates a transport that rotates between accounts in
+// the given mode using transports created by newTransport
+func NewRotatingTransport(accounts []*Account, mode string, newTransport func(*Account) (Transport, error)) (*RotatingTransport, error) {
+	if len(accounts) == 0 {
+		return nil, errors.New("no seeking alpha accounts configured")
+	}
+
+	switch mode {
+	case "":
+		mode = ROTATION_PAGE
+	case ROTATION_PAGE, ROTATION_FAILOVER:
+	default:
+		return nil, fmt.Errorf("unknown account rotation %s", mode)
+	}
+
+	slots := make([]*accountSlot, len(accounts))
+	for idx, account := range accounts {
+		slots[idx] = &accountSlot{account: account}
+	}
+
+	return &RotatingTransport{
+		Mode:         mode,
+		slots:        slots,
+		newTransport: newTransport,
+	}, nil
+}
+
+// StartWithLeastUsed begins the rotation with the account that has made the
+// fewest requests according to usage, e.g. the counts saved by earlier runs
+func (transport *RotatingTransport) StartWithLeastUsed(usage map[string]*AccountUsage) {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	var fewest int64 = -1
+	for idx, slot := range transport.slots {
+		var requests int64
+		if previous, ok := usage[slot.account.Name]; ok {
+			requests = previous.Requests
+		}
+		if fewest < 0 || requests < fewest {
+			fewest = requests
+			transport.start = idx
+		}
+	}
+
+	transport.current = transport.start
+	log.Info().Str("Account", transport.slots[transport.start].account.Name).Int64("Requests", fewest).Msg("starting with least used account")
+}
+
+func (transport *RotatingTransport) Screener(pageNum int, args []byte) ([]byte, error) {
+	if transport.Mode == ROTATION_PAGE {
+		transport.rotate(pageNum)
+	}
+
+	return transport.do(func(t Transport) ([]byte, error) {
+		return t.Screener(pageNum, args)
+	})
+}
+
+func (transport *RotatingTransport) Metrics(request *MetricRequest, slugs []string) ([]byte, error) {
+	return transport.do(func(t Transport) ([]byte, error) {
+		return t.Metrics(request, slugs)
+	})
+}
+
+// Relogin logs in to the account requests are currently sent with and
+// reloads its transport
+func (transport *RotatingTransport) Relogin() error {
+	slot, t, err := transport.active()
+	if err != nil {
+		return err
+	}
+
+	return loginAndReload(slot.account, t)
+}
+
+// SolveChallenge solves the challenge of the first blocked account whose
+// transport can solve challenges and sends requests with it again
+func (transport *RotatingTransport) SolveChallenge() error {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	var err error
+	for idx, slot := range transport.slots {
+		solver, ok := slot.transport.(ChallengeSolver)
+		if !slot.blocked || !ok {
+			continue
+		}
+
+		log.Info().Str("Account", slot.account.Name).Msg("solving challenge of blocked account")
+		if solveErr := solver.SolveChallenge(); solveErr != nil {
+			err = errors.Join(err, solveErr)
+			continue
+		}
+
+		slot.blocked = false
+		transport.current = idx
+		return nil
+	}
+
+	if err == nil {
+		err = errors.New("no blocked account can solve challenges")
+	}
+	return err
+}
+
+// Reload reloads the session of every account that has been used
+func (transport *RotatingTransport) Reload() error {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	var err error
+	for _, slot := range transport.slots {
+		if reloader, ok := slot.transport.(Reloader); ok {
+			err = errors.Join(err, reloader.Reload())
+		}
+	}
+	return err
+}
+
+// Usage returns the request counts of each account during this run
+func (transport *RotatingTransport) Usage() map[string]AccountUsage {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	usage := make(map[string]AccountUsage, len(transport.slots))
+	for _, slot := range transport.slots {
+		usage[slot.account.Name] = slot.usage
+	}
+	return usage
+}
+
+func (transport *RotatingTransport) Close() error {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	var err error
+	for _, slot := range transport.slots {
+		log.Info().Str("Account", slot.account.Name).Int64("Requests", slot.usage.Requests).Int64("Errors", slot.usage.Errors).Int64("Blocked", slot.usage.Blocked).Msg("account usage")
+		if slot.transport != nil {
+			err = errors.Join(err, slot.transport.Close())
+			slot.transport = nil
+		}
+	}
+
+	if transport.UsageFile != "" {
+		if saveErr := transport.saveUsage(); saveErr != nil {
+			log.Error().Err(saveErr).Str("UsageFile", transport.UsageFile).Msg("could not save account usage")
+			err = errors.Join(err, saveErr)
+		}
+	}
+
+	return err
+}
+
+// saveUsage adds the request counts of this run to the usage file; the
+// caller must hold mu
+func (transport *RotatingTransport) saveUsage() error {
+	usage, err := ReadAccountUsage(transport.UsageFile)
+	if err != nil {
+		return err
+	}
+
+	for _, slot := range transport.slots {
+		if slot.usage.Requests == 0 {
+			continue
+		}
+
+		total, ok := usage[slot.account.Name]
+		if !ok {
+			total = &AccountUsage{}
+			usage[slot.account.Name] = total
+		}
+		total.Requests += slot.usage.Requests
+		total.Errors += slot.usage.Errors
+		total.Blocked += slot.usage.Blocked
+		total.LastUsed = slot.usage.LastUsed
+	}
+
+	return WriteAccountUsage(transport.UsageFile, usage)
+}
+
+// rotate switches to the account of pageNum, skipping blocked accounts.
+// Screener pages start at 1 so the first page is sent with the start account.
+func (transport *RotatingTransport) rotate(pageNum int) {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	numSlots := len(transport.slots)
+	for ii := 0; ii < numSlots; ii++ {
+		idx := (transport.start + pageNum - 1 + ii) % numSlots
+		if !transport.slots[idx].blocked {
+			transport.current = idx
+			return
+		}
+	}
+}
+
+// active returns the account requests are currently sent with and its
+// transport, creating it if necessary. Accounts whose transport cannot be
+// created are skipped.
+func (transport *RotatingTransport) active() (*accountSlot, Transport, error) {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	for {
+		slot := transport.slots[transport.current]
+		if slot.blocked {
+			if !transport.advance() {
+				return nil, nil, errors.Join(ErrBlocked, ErrAccountsBlocked)
+			}
+			continue
+		}
+
+		if slot.transport != nil {
+			return slot, slot.transport, nil
+		}
+
+		log.Info().Object("Account", slot.account).Msg("creating transport for account")
+		t, err := transport.newTransport(slot.account)
+		if err != nil {
+			log.Error().Err(err).Str("Account", slot.account.Name).Msg("could not create transport for account; skipping it")
+			slot.blocked = true
+			continue
+		}
+		slot.transport = t
+		return slot, t, nil
+	}
+}
+
+// advance moves to the next account that is not blocked and returns false if
+// there is none; the caller must hold mu
+func (transport *RotatingTransport) advance() bool {
+	numSlots := len(transport.slots)
+	for ii := 1; ii <= numSlots; ii++ {
+		idx := (transport.current + ii) % numSlots
+		if !transport.slots[idx].blocked {
+			transport.current = idx
+			return true
+		}
+	}
+	return false
+}
+
+func (transport *RotatingTransport) do(call func(Transport) ([]byte, error)) ([]byte, error) {
+	for {
+		slot, t, err := transport.active()
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := call(t)
+		blocked := isAccountBlocked(body, err)
+		transport.record(slot, err, blocked)
+		if !blocked {
+			return body, err
+		}
+
+		log.Warn().Err(err).Str("Account", slot.account.Name).Msg("account was blocked; switching to the next account")
+	}
+}
+
+// record counts a request made with slot and marks the account as blocked
+func (transport *RotatingTransport) record(slot *accountSlot, err error, blocked bool) {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	slot.usage.Requests++
+	slot.usage.LastUsed = time.Now()
+	if err != nil {
+		slot.usage.Errors++
+	}
+	if blocked {
+		slot.usage.Blocked++
+		slot.blocked = true
+	}
+}
+
+// isAccountBlocked returns true if a response shows that the account was
+// challenged or forbidden. An expired session (401) is not a block; it is
+// renewed by logging in to the same account again.
+func isAccountBlocked(body []byte, err error) bool {
+	if err == nil {
+		return isBlockPage(body)
+	}
+
+	if errors.Is(err, ErrBlocked) {
+		return true
+	}
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+
+	return statusErr.Status == http.StatusForbidden || isChallenge(statusErr.Status, statusErr.Header, statusErr.Body)
+}
@@ -0,0 +1,99 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	"fmt"
+	"testing"
+)
+
+// newFakeRotation creates a RotatingTransport over the named accounts where
+// every account has its own FakeTransport serving numPages screener pages
+func newFakeRotation(t *testing.T, mode string, names []string, numPages int) (*RotatingTransport, map[string]*FakeTransport) {
+	t.Helper()
+
+	accounts := make([]*Account, 0, len(names))
+	fakes := make(map[string]*FakeTransport, len(names))
+	for _, name := range names {
+		accounts = append(accounts, &Account{Name: name, StateFile: fmt.Sprintf("state-%s.json", name)})
+
+		fake := NewFakeTransport()
+		for pageNum := 1; pageNum <= numPages; pageNum++ {
+			fake.ScreenerPages[pageNum] = []byte(`{"data":[],"meta":{"count":0}}`)
+		}
+		fakes[name] = fake
+	}
+
+	transport, err := NewRotatingTransport(accounts, mode, func(account *Account) (Transport, error) {
+		return fakes[account.Name], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return transport, fakes
+}
+
+func TestRotatingTransportStartsWithLeastUsed(t *testing.T) {
+	transport, fakes := newFakeRotation(t, ROTATION_PAGE, []string{"a", "b", "c"}, 4)
+	transport.StartWithLeastUsed(map[string]*AccountUsage{
+		"a": {Requests: 10},
+		"b": {Requests: 2},
+		"c": {Requests: 5},
+	})
+
+	for pageNum := 1; pageNum <= 4; pageNum++ {
+		if _, err := transport.Screener(pageNum, nil); err != nil {
+			t.Fatalf("page %d: %v", pageNum, err)
+		}
+	}
+
+	expected := map[string][]string{
+		"a": {"screener:3"},
+		"b": {"screener:1", "screener:4"},
+		"c": {"screener:2"},
+	}
+	for name, requests := range expected {
+		if fmt.Sprint(fakes[name].Requests) != fmt.Sprint(requests) {
+			t.Errorf("account %s: expected requests %v, got %v", name, requests, fakes[name].Requests)
+		}
+	}
+}
+
+func TestRotatingTransportSkipsBlockedAccount(t *testing.T) {
+	transport, fakes := newFakeRotation(t, ROTATION_PAGE, []string{"a", "b"}, 2)
+
+	// page 1 belongs to a, which is blocked, so it is repeated with b
+	fakes["a"].ScreenerPages[1] = []byte(`<html><div id="px-captcha"></div></html>`)
+
+	if _, err := transport.Screener(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transport.Screener(2, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(fakes["a"].Requests) != "[screener:1]" {
+		t.Errorf("account a: unexpected requests %v", fakes["a"].Requests)
+	}
+	if fmt.Sprint(fakes["b"].Requests) != "[screener:1 screener:2]" {
+		t.Errorf("account b: unexpected requests %v", fakes["b"].Requests)
+	}
+
+	usage := transport.Usage()
+	if usage["a"].Blocked != 1 || usage["b"].Requests != 2 {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// FakeTransport serves canned responses from memory. Screener responses are
+// keyed by page number and metrics responses by metric group name.
+type FakeTransport struct {
+	ScreenerPages map[int][]byte
+	MetricGroups  map[string][]byte
+
+	// Requests lists every request made in the form "screener:<page>" or
+	// "<group>:<slug>,<slug>,..."
+	Requests []string
+
+	mu sync.Mutex
+}
+
+// NewFakeTransport creates a FakeTransport with no responses
+func NewFakeTransport() *FakeTransport {
+	return &FakeTransport{
+		ScreenerPages: make(map[int][]byte),
+		MetricGroups:  make(map[string][]byte),
+		Requests:      make([]string, 0),
+	}
+}
+
+func (transport *FakeTransport) Screener(pageNum int, args []byte) ([]byte, error) {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	transport.Requests = append(transport.Requests, fmt.Sprintf("%s:%d", ARCHIVE_SCREENER_ENDPOINT, pageNum))
+	if body, ok := transport.ScreenerPages[pageNum]; ok {
+		return body, nil
+	}
+
+	return nil, &StatusError{Url: SCREENER_API_URL, Status: 404}
+}
+
+func (transport *FakeTransport) Metrics(request *MetricRequest, slugs []string) ([]byte, error) {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	transport.Requests = append(transport.Requests, fmt.Sprintf("%s:%s", request.Group, strings.Join(slugs, ",")))
+	if body, ok := transport.MetricGroups[request.Group]; ok {
+		return body, nil
+	}
+
+	return nil, &StatusError{Url: metricsUrl(request, slugs), Status: 404}
+}
+
+func (transport *FakeTransport) Close() error {
+	return nil
+}
@@ -0,0 +1,184 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// FallbackTransport sends requests with a lightweight primary transport and
+// switches to a fallback transport, which is only created when needed, the
+// first time the primary is blocked or challenged. The blocked request is
+// repeated with the fallback and every later request uses it.
+type FallbackTransport struct {
+	primary      Transport
+	newFallback  func() (Transport, error)
+	fallback     Transport
+	fallbackErr  error
+	usedFallback bool
+
+	mu sync.Mutex
+}
+
+// NewFallbackTransport creates a transport that uses primary until it is
+// blocked and then the transport returned by newFallback
+func NewFallbackTransport(primary Transport, newFallback func() (Transport, error)) *FallbackTransport {
+	return &FallbackTransport{
+		primary:     primary,
+		newFallback: newFallback,
+	}
+}
+
+// NewHttpFallbackTransport creates a transport that calls the Seeking Alpha API
+// with net/http and falls back to the browser when the request is blocked;
+// both use the session and proxy of account
+func NewHttpFallbackTransport(account *Account) (*FallbackTransport, error) {
+	primary, err := NewHttpTransport(account)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFallbackTransport(primary, func() (Transport, error) {
+		return NewPlaywrightTransport(account)
+	}), nil
+}
+
+func (transport *FallbackTransport) Screener(pageNum int, args []byte) ([]byte, error) {
+	return transport.do(func(t Transport) ([]byte, error) {
+		return t.Screener(pageNum, args)
+	})
+}
+
+func (transport *FallbackTransport) Metrics(request *MetricRequest, slugs []string) ([]byte, error) {
+	return transport.do(func(t Transport) ([]byte, error) {
+		return t.Metrics(request, slugs)
+	})
+}
+
+// Reload reloads the session of the primary transport and, if it has been
+// created, the fallback transport
+func (transport *FallbackTransport) Reload() error {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	var err error
+	if reloader, ok := transport.primary.(Reloader); ok {
+		err = reloader.Reload()
+	}
+	if reloader, ok := transport.fallback.(Reloader); ok {
+		err = errors.Join(err, reloader.Reload())
+	}
+	return err
+}
+
+// SolveChallenge solves the challenge with the transport requests are
+// currently sent with; the primary transport cannot solve challenges so the
+// fallback is created if necessary
+func (transport *FallbackTransport) SolveChallenge() error {
+	active := transport.active()
+	if active == transport.primary {
+		if solver, ok := active.(ChallengeSolver); ok {
+			return solver.SolveChallenge()
+		}
+
+		var err error
+		if active, err = transport.switchToFallback(); err != nil {
+			return err
+		}
+	}
+
+	if solver, ok := active.(ChallengeSolver); ok {
+		return solver.SolveChallenge()
+	}
+	return errors.New("transport cannot solve challenges")
+}
+
+func (transport *FallbackTransport) Close() error {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	err := transport.primary.Close()
+	if transport.fallback != nil {
+		err = errors.Join(err, transport.fallback.Close())
+	}
+	return err
+}
+
+func (transport *FallbackTransport) do(call func(Transport) ([]byte, error)) ([]byte, error) {
+	if active := transport.active(); active != transport.primary {
+		return call(active)
+	}
+
+	body, err := call(transport.primary)
+	if !isBlockedResponse(body, err) {
+		return body, err
+	}
+
+	log.Warn().Err(err).Msg("request was blocked; falling back to the browser")
+	fallback, fallbackErr := transport.switchToFallback()
+	if fallbackErr != nil {
+		return body, errors.Join(err, fallbackErr)
+	}
+
+	return call(fallback)
+}
+
+// active returns the transport requests are currently sent with
+func (transport *FallbackTransport) active() Transport {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	if transport.usedFallback && transport.fallback != nil {
+		return transport.fallback
+	}
+	return transport.primary
+}
+
+// switchToFallback creates the fallback transport once; concurrent requests
+// that are blocked at the same time share it
+func (transport *FallbackTransport) switchToFallback() (Transport, error) {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	if !transport.usedFallback {
+		transport.usedFallback = true
+		transport.fallback, transport.fallbackErr = transport.newFallback()
+		if transport.fallbackErr != nil {
+			log.Error().Err(transport.fallbackErr).Msg("could not create fallback transport")
+			transport.fallback = nil
+		}
+	}
+
+	return transport.fallback, transport.fallbackErr
+}
+
+// isBlockedResponse returns true if a response shows that the session was
+// rejected or challenged rather than failing for a transient reason
+func isBlockedResponse(body []byte, err error) bool {
+	if err == nil {
+		return isBlockPage(body)
+	}
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+
+	return statusErr.Status == http.StatusUnauthorized || statusErr.Status == http.StatusForbidden || isChallenge(statusErr.Status, statusErr.Header, statusErr.Body)
+}
@@ -0,0 +1,190 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/penny-vault/import-sa-quant-rank/common"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const SA_ORIGIN string = `https://seekingalpha.com`
+
+// HttpTransport calls the Seeking Alpha API directly with net/http using the
+// cookies saved in the playwright state file
+type HttpTransport struct {
+	StateFile string
+
+	// BaseUrl replaces https://seekingalpha.com in every request
+	BaseUrl   string
+	UserAgent string
+	Cookies   []*http.Cookie
+	Client    *http.Client
+}
+
+// NewHttpTransport creates a transport that uses the cookies saved in the
+// state file of account and sends requests through its proxy; the
+// http.base_url configuration value overrides the Seeking Alpha origin
+func NewHttpTransport(account *Account) (*HttpTransport, error) {
+	cookies, stateUserAgent, err := loadState(account.StateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// the configured user agent takes precedence over the one the cookies
+	// were saved with
+	userAgent := viper.GetString("playwright.user_agent")
+	if userAgent == "" {
+		userAgent = stateUserAgent
+	}
+	if userAgent == "" {
+		log.Warn().Msg("state file has no user agent; requests may be blocked")
+	}
+
+	baseUrl := viper.GetString("http.base_url")
+	if baseUrl == "" {
+		baseUrl = SA_ORIGIN
+	}
+
+	client := &http.Client{
+		Timeout: 60 * time.Second,
+	}
+	if account.Proxy != "" {
+		proxyUrl, err := url.Parse(account.Proxy)
+		if err != nil {
+			log.Error().Err(err).Str("Proxy", account.Proxy).Msg("could not parse proxy url")
+			return nil, err
+		}
+		client.Transport = &http.Transport{Proxy: http.ProxyURL(proxyUrl)}
+	}
+
+	transport := &HttpTransport{
+		StateFile: account.StateFile,
+		BaseUrl:   strings.TrimSuffix(baseUrl, "/"),
+		UserAgent: userAgent,
+		Cookies:   cookies,
+		Client:    client,
+	}
+
+	log.Info().Str("BaseUrl", transport.BaseUrl).Int("NumCookies", len(cookies)).Str("UserAgent", userAgent).Msg("using http transport")
+	return transport, nil
+}
+
+// loadState reads the Seeking Alpha cookies and the user agent they were
+// saved with from a playwright state file
+func loadState(stateFileName string) ([]*http.Cookie, string, error) {
+	state, err := common.ReadSessionState(stateFileName)
+	if err != nil {
+		log.Error().Err(err).Str("StateFile", stateFileName).Msg("could not read state file")
+		return nil, "", err
+	}
+
+	cookies := make([]*http.Cookie, 0, len(state.Cookies))
+	for _, cookie := range state.Cookies {
+		if !strings.HasSuffix(cookie.Domain, "seekingalpha.com") {
+			continue
+		}
+		cookies = append(cookies, &http.Cookie{
+			Name:  cookie.Name,
+			Value: cookie.Value,
+		})
+	}
+
+	return cookies, state.UserAgent, nil
+}
+
+func (transport *HttpTransport) Screener(pageNum int, args []byte) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodPost, transport.url(SCREENER_API_URL), bytes.NewReader(args))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Cache-Control", "no-cache")
+
+	return transport.do(req)
+}
+
+func (transport *HttpTransport) Metrics(request *MetricRequest, slugs []string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, transport.url(metricsUrl(request, slugs)), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return transport.do(req)
+}
+
+// Reload reads the cookies and user agent from the state file again
+func (transport *HttpTransport) Reload() error {
+	cookies, stateUserAgent, err := loadState(transport.StateFile)
+	if err != nil {
+		return err
+	}
+
+	transport.Cookies = cookies
+	if userAgent := viper.GetString("playwright.user_agent"); userAgent != "" {
+		transport.UserAgent = userAgent
+	} else if stateUserAgent != "" {
+		transport.UserAgent = stateUserAgent
+	}
+
+	log.Info().Int("NumCookies", len(cookies)).Msg("reloaded http session")
+	return nil
+}
+
+func (transport *HttpTransport) Close() error {
+	transport.Client.CloseIdleConnections()
+	return nil
+}
+
+func (transport *HttpTransport) url(saUrl string) string {
+	return transport.BaseUrl + strings.TrimPrefix(saUrl, SA_ORIGIN)
+}
+
+func (transport *HttpTransport) do(req *http.Request) ([]byte, error) {
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Referer", SCREENER_PAGE_URL)
+	if transport.UserAgent != "" {
+		req.Header.Set("User-Agent", transport.UserAgent)
+	}
+	for _, cookie := range transport.Cookies {
+		req.AddCookie(cookie)
+	}
+
+	resp, err := transport.Client.Do(req)
+	if err != nil {
+		log.Error().Err(err).Str("Url", req.URL.String()).Msg("http request failed")
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Error().Err(err).Str("Url", req.URL.String()).Msg("error reading response body")
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK || isHtml(body) {
+		return nil, &StatusError{Url: req.URL.String(), Status: resp.StatusCode, Body: body, Header: resp.Header}
+	}
+
+	return body, nil
+}
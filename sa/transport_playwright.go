@@ -0,0 +1,470 @@
+// Copyright 2022
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sa
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/penny-vault/import-sa-quant-rank/common"
+	"github.com/playwright-community/playwright-go"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// maxBrowserRestarts limits how often a single request restarts the browser
+const maxBrowserRestarts = 2
+
+// stateSaveTimeout bounds how long a restart waits for the browser, which may
+// have stopped responding, to return its session state
+const stateSaveTimeout = 10 * time.Second
+
+// PlaywrightTransport fetches Seeking Alpha responses by evaluating fetch()
+// inside a browser page that has the screener loaded. Metrics requests are
+// spread across a pool of pages so they can be made concurrently.
+//
+// The browser is supervised: when it crashes, its context is closed or a page
+// stops responding, the browser is restarted, the screener page is reloaded
+// and the request is repeated. The browser is also restarted every
+// RestartInterval screener pages served by the same browser as a safety net.
+type PlaywrightTransport struct {
+	Account         *Account
+	RestartInterval int
+	Timeout         time.Duration
+
+	numPages int
+	session  *browserSession
+	mu       sync.RWMutex
+}
+
+// browserSession is a single launch of the browser and its pool of pages
+type browserSession struct {
+	page    playwright.Page
+	context playwright.BrowserContext
+	browser playwright.Browser
+	pw      *playwright.Playwright
+
+	idle chan playwright.Page
+
+	// failed is set by the browser, context and page event handlers
+	failed atomic.Bool
+
+	// screenerPages counts the screener pages served by this browser
+	screenerPages atomic.Int64
+}
+
+// NewPlaywrightTransport launches a browser with the session and proxy of
+// account and loads the screener page in one page per metrics worker
+func NewPlaywrightTransport(account *Account) (*PlaywrightTransport, error) {
+	numPages := viper.GetInt("workers")
+	if numPages < 1 {
+		numPages = 1
+	}
+
+	transport := &PlaywrightTransport{
+		Account:         account,
+		RestartInterval: viper.GetInt("playwright.restart_interval"),
+		Timeout:         viper.GetDuration("playwright.request_timeout"),
+		numPages:        numPages,
+	}
+
+	session, err := transport.start()
+	if err != nil {
+		return nil, err
+	}
+	transport.session = session
+
+	return transport, nil
+}
+
+func (transport *PlaywrightTransport) start() (*browserSession, error) {
+	session := &browserSession{}
+	var err error
+	session.page, session.context, session.browser, session.pw, err = common.StartPlaywright(viper.GetBool("playwright.headless"), transport.Account.StateFile, transport.Account.Proxy)
+	if err != nil {
+		log.Error().Err(err).Object("Account", transport.Account).Msg("could not start browser")
+		return nil, err
+	}
+
+	session.browser.OnDisconnected(func(playwright.Browser) {
+		log.Warn().Msg("browser disconnected")
+		session.failed.Store(true)
+	})
+	session.context.OnClose(func(playwright.BrowserContext) {
+		log.Warn().Msg("browser context closed")
+		session.failed.Store(true)
+	})
+
+	// the main page is shared with the first metrics worker
+	session.idle = make(chan playwright.Page, transport.numPages)
+	for ii := 0; ii < transport.numPages; ii++ {
+		page := session.page
+		if ii > 0 {
+			if page, err = common.StealthPage(&session.context); err != nil {
+				common.ClosePlaywright(session.browser, session.pw)
+				return nil, err
+			}
+		}
+
+		page.OnCrash(func(playwright.Page) {
+			log.Warn().Msg("browser page crashed")
+			session.failed.Store(true)
+		})
+
+		if err := loadScreenerPage(page); err != nil {
+			common.ClosePlaywright(session.browser, session.pw)
+			return nil, err
+		}
+		session.idle <- page
+	}
+
+	return session, nil
+}
+
+func (transport *PlaywrightTransport) stop(session *browserSession) {
+	common.StopPlaywright(session.page, session.context, session.browser, session.pw, transport.Account.StateFile)
+}
+
+// saveState saves the session state of a browser that may have stopped
+// responding, giving up after stateSaveTimeout
+func (transport *PlaywrightTransport) saveState(session *browserSession) {
+	done := make(chan error, 1)
+	go func() {
+		done <- common.SavePlaywrightState(session.page, session.context, transport.Account.StateFile)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(stateSaveTimeout):
+		log.Warn().Dur("Timeout", stateSaveTimeout).Msg("browser did not return its session state; restarting without saving it")
+	}
+}
+
+// restart replaces session with a new browser unless another request has
+// already restarted it
+func (transport *PlaywrightTransport) restart(session *browserSession) error {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	if transport.session != session {
+		return nil
+	}
+
+	transport.saveState(session)
+	common.ClosePlaywright(session.browser, session.pw)
+	transport.session = nil
+
+	replacement, err := transport.start()
+	if err != nil {
+		log.Error().Err(err).Msg("could not restart browser")
+		return err
+	}
+	transport.session = replacement
+
+	return nil
+}
+
+// current returns the running browser session, starting one if a previous
+// restart failed
+func (transport *PlaywrightTransport) current() (*browserSession, error) {
+	transport.mu.RLock()
+	session := transport.session
+	transport.mu.RUnlock()
+
+	if session != nil {
+		return session, nil
+	}
+
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	if transport.session == nil {
+		replacement, err := transport.start()
+		if err != nil {
+			return nil, err
+		}
+		transport.session = replacement
+	}
+
+	return transport.session, nil
+}
+
+// healthy returns false if the browser, its context or page has failed or the
+// error shows that the page stopped responding
+func (session *browserSession) healthy(page playwright.Page, err error) bool {
+	if session.failed.Load() || !session.browser.IsConnected() || page.IsClosed() {
+		return false
+	}
+
+	if err == nil {
+		return true
+	}
+
+	return !errors.Is(err, playwright.TimeoutError) && !strings.HasSuffix(err.Error(), "has been closed")
+}
+
+// withPage runs fn with an idle page of the current browser. If the browser
+// turns out to be unhealthy it is restarted and fn is repeated.
+func (transport *PlaywrightTransport) withPage(fn func(page playwright.Page) ([]byte, error)) ([]byte, error) {
+	for restarts := 0; ; restarts++ {
+		session, err := transport.current()
+		if err != nil {
+			return nil, err
+		}
+
+		// each page has at most one request in flight so the expected
+		// response cannot belong to another worker
+		page := <-session.idle
+		body, err := fn(page)
+		session.idle <- page
+
+		if session.healthy(page, err) || restarts >= maxBrowserRestarts {
+			return body, err
+		}
+
+		log.Warn().Err(err).Int("Restarts", restarts).Msg("browser is unhealthy; restarting and repeating request")
+		if restartErr := transport.restart(session); restartErr != nil {
+			return nil, errors.Join(err, restartErr)
+		}
+	}
+}
+
+// expectOptions bounds how long a page may take to respond
+func (transport *PlaywrightTransport) expectOptions() playwright.PageExpectResponseOptions {
+	opts := playwright.PageExpectResponseOptions{}
+	if transport.Timeout > 0 {
+		opts.Timeout = playwright.Float(float64(transport.Timeout.Milliseconds()))
+	}
+	return opts
+}
+
+func loadScreenerPage(page playwright.Page) error {
+	// Block unnessessary requests
+	setupPageBlocks(page)
+
+	// Load the screeners page
+	if _, err := page.Goto(SCREENER_PAGE_URL, playwright.PageGotoOptions{
+		WaitUntil: playwright.WaitUntilStateNetworkidle,
+	}); err != nil {
+		log.Error().Err(err).Msg("could not load activity page")
+		return err
+	}
+
+	return nil
+}
+
+func (transport *PlaywrightTransport) Screener(pageNum int, args []byte) ([]byte, error) {
+	if transport.RestartInterval > 0 {
+		session, err := transport.current()
+		if err != nil {
+			return nil, err
+		}
+		if served := session.screenerPages.Load(); served >= int64(transport.RestartInterval) {
+			log.Info().Int("PageNum", pageNum).Int64("PagesServed", served).Int("RestartInterval", transport.RestartInterval).Msg("restarting browser")
+			if err := transport.restart(session); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	body, err := transport.withPage(func(page playwright.Page) ([]byte, error) {
+		resp, err := page.ExpectResponse(SCREENER_API_URL, func() error {
+			_, err := page.Evaluate(`(params) => {
+            fetch('https://seekingalpha.com/api/v3/screener_results', {
+                method: 'POST',
+                cache: 'no-cache',
+                headers: {
+                    'Content-Type': 'application/json'
+                },
+                body: params,
+            });
+        }`, string(args))
+			if err != nil {
+				log.Error().Err(err).Msg("error in page evaluate")
+			}
+			return err
+		}, transport.expectOptions())
+		if err != nil {
+			log.Error().Err(err).Msg("failed waiting for response for POST SCREENER_URL")
+			return nil, err
+		}
+
+		return readPlaywrightResponse(resp, SCREENER_API_URL)
+	})
+
+	// the count belongs to the browser that served the page, which is a new
+	// one if withPage had to restart it
+	if err == nil {
+		if session, currentErr := transport.current(); currentErr == nil {
+			session.screenerPages.Add(1)
+		}
+	}
+
+	return body, err
+}
+
+func (transport *PlaywrightTransport) Metrics(request *MetricRequest, slugs []string) ([]byte, error) {
+	myUrl := metricsUrl(request, slugs)
+
+	return transport.withPage(func(page playwright.Page) ([]byte, error) {
+		resp, err := page.ExpectResponse("**/api/v3/*metric*", func() error {
+			_, err := page.Evaluate(`(url) => {
+                    fetch(url);
+                }`, myUrl)
+			if err != nil {
+				log.Error().Err(err).Str("Url", myUrl).Msg("error in metrics page evaluate")
+			}
+			return err
+		}, transport.expectOptions())
+		if err != nil {
+			log.Error().Err(err).Msg("error in expect response")
+			return nil, err
+		}
+
+		return readPlaywrightResponse(resp, myUrl)
+	})
+}
+
+// SolveChallenge reloads the screener page in one of the browser pages and,
+// if PerimeterX shows its press and hold button, solves it. The pages share a
+// context so clearing the challenge once clears it for every page.
+func (transport *PlaywrightTransport) SolveChallenge() error {
+	session, err := transport.current()
+	if err != nil {
+		return err
+	}
+
+	page := <-session.idle
+	defer func() { session.idle <- page }()
+
+	if _, err := page.Goto(SCREENER_PAGE_URL, playwright.PageGotoOptions{
+		WaitUntil: playwright.WaitUntilStateNetworkidle,
+	}); err != nil {
+		log.Error().Err(err).Msg("could not reload screener page")
+		return err
+	}
+
+	if !common.HasCaptcha(page) {
+		log.Info().Msg("screener page has no challenge")
+		return nil
+	}
+
+	if err := common.SolveCaptcha(page, viper.GetDuration("challenge.hold_timeout")); err != nil {
+		return err
+	}
+
+	// load the screener page again now that the challenge has been cleared
+	if _, err := page.Goto(SCREENER_PAGE_URL, playwright.PageGotoOptions{
+		WaitUntil: playwright.WaitUntilStateNetworkidle,
+	}); err != nil {
+		log.Error().Err(err).Msg("could not reload screener page")
+		return err
+	}
+
+	if common.HasCaptcha(page) {
+		return common.ErrCaptchaTimeout
+	}
+
+	return nil
+}
+
+// Reload restarts the browser with the session in the state file, discarding
+// the session of the running browser
+func (transport *PlaywrightTransport) Reload() error {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	if transport.session != nil {
+		common.ClosePlaywright(transport.session.browser, transport.session.pw)
+		transport.session = nil
+	}
+
+	session, err := transport.start()
+	if err != nil {
+		return err
+	}
+	transport.session = session
+	return nil
+}
+
+func (transport *PlaywrightTransport) Close() error {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	if transport.session != nil {
+		transport.stop(transport.session)
+		transport.session = nil
+	}
+	return nil
+}
+
+func readPlaywrightResponse(resp playwright.Response, url string) ([]byte, error) {
+	body, err := resp.Body()
+	if err != nil {
+		log.Error().Err(err).Str("Url", url).Msg("error reading response body")
+		return nil, err
+	}
+
+	if resp.Status() != 200 || isHtml(body) {
+		header := http.Header{}
+		if values, err := resp.HeaderValues("set-cookie"); err == nil {
+			// chromium joins multiple cookies with new lines
+			for _, value := range values {
+				for _, cookie := range strings.Split(value, "\n") {
+					header.Add("Set-Cookie", cookie)
+				}
+			}
+		}
+		return nil, &StatusError{Url: url, Status: resp.Status(), Body: body, Header: header}
+	}
+
+	return body, nil
+}
+
+func setupPageBlocks(page playwright.Page) {
+	// block a variety of domains that contain trackers and ads
+	page.Route("**/*", func(route playwright.Route) {
+		request := route.Request()
+		if strings.Contains(request.URL(), "google.com") ||
+			strings.Contains(request.URL(), "facebook.com") ||
+			strings.Contains(request.URL(), "adsystem.com") ||
+			strings.Contains(request.URL(), "sitescout.com") ||
+			strings.Contains(request.URL(), "ipredictive.com") ||
+			strings.Contains(request.URL(), "eyeota.net") ||
+			// strings.Contains(request.URL(), "collect") ||
+			strings.Contains(request.URL(), "beacon") ||
+			strings.Contains(request.URL(), "mone") ||
+			strings.Contains(request.URL(), "mone_event") {
+			err := route.Abort("failed")
+			if err != nil {
+				log.Error().Err(err).Msg("failed blocking route")
+			}
+			return
+		}
+
+		if request.ResourceType() == "image" {
+			err := route.Abort("failed")
+			if err != nil {
+				log.Error().Err(err).Msg("failed blocking image")
+			}
+		}
+
+		route.Continue()
+	})
+}
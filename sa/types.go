@@ -31,98 +31,125 @@ type Ticker struct {
 	IsDefunct      bool   `json:"isDefunct"`
 	FollowersCount int    `json:"followersCount"`
 	IsReit         bool   `json:"isReit"`
+	Sector         string `json:"sectorname"`
+	Industry       string `json:"primaryname"`
 }
 
 type SeekingAlphaRecord struct {
 	DateStr                      string `json:"date" parquet:"name=Date, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
 	Date                         time.Time
-	TickerId                     int     `json:"tickerId" parquet:"name=SeekingAlphaTickerId, type=INT32"`
-	Ticker                       string  `json:"ticker" parquet:"name=Ticker, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
-	CompositeFigi                string  `json:"compositeFigi" parquet:"name=CompositeFigi, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
-	CompanyName                  string  `json:"companyName" parquet:"name=CompanyName, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
-	Exchange                     string  `json:"exchange" parquet:"name=Exchange, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
-	Type                         string  `json:"type" parquet:"name=Type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
-	FollowersCount               int     `parquet:"name=FollowersCount, type=INT32"`
-	MarketCap                    float64 `json:"marketcap_display" parquet:"name=MarketCap, type=DOUBLE"`
-	QuantRating                  float32 `json:"quant_rating" parquet:"name=QuantRating, type=FLOAT"`
-	AuthorsRatingPro             float32 `json:"authors_rating_pro" parquet:"name=AuthorsRatingPro, type=FLOAT"`
-	SellSideRating               float32 `json:"sell_side_rating" parquet:"name=SellSideRating, type=FLOAT"`
-	ValueCategory                float32 `json:"value_category" parquet:"name=ValueCategory, type=FLOAT"`
-	GrowthCategory               float32 `json:"growth_category" parquet:"name=GrowthCategory, type=FLOAT"`
-	ProfitabilityCategory        float32 `json:"profitability_category" parquet:"name=ProfitabilityCategory, type=FLOAT"`
-	MomentumCategory             float32 `json:"momentum_category" parquet:"name=MomentumCategory, type=FLOAT"`
-	EpsRevisionsCategory         float32 `json:"eps_revisions_category" parquet:"name=EpsRevisionsCategory, type=FLOAT"`
-	EarningAnnounceTimestamp     int64   `json:"earning_announce_date" parquet:"name=EarningAnnounceTimestamp, type=INT64"`
-	EpsEstimateFy1               float64 `json:"eps_estimate_fy1" parquet:"name=EpsEstimateFy1, type=DOUBLE"`
-	RevenueEstimate              float64 `json:"revenue_estimate" parquet:"name=RevenueEstimate, type=DOUBLE"`
-	EpsNormalizedActual          float32 `json:"eps_normalized_actual" parquet:"name=EpsNormalizedActual, type=FLOAT"`
-	EpsSurprise                  float32 `json:"eps_surprise" parquet:"name=EpsSurprise, type=FLOAT"`
-	RevenueActual                float64 `json:"revenue_actual" parquet:"name=RevenueActual, type=DOUBLE"`
-	RevenueSurprise              float64 `json:"revenue_surprise" parquet:"name=RevenueSurprise, type=DOUBLE"`
-	Tev                          float64 `json:"tev" parquet:"name=Tev, type=DOUBLE"`
-	PeRatio                      float32 `json:"pe_ratio" parquet:"name=PeRatio, type=FLOAT"`
-	PeNonGaapFy1                 float32 `json:"pe_nongaap_fy1" parquet:"name=PeNonGaapFy1, type=FLOAT"`
-	PsRatio                      float32 `json:"ps_ratio" parquet:"name=PsRatio, type=FLOAT"`
-	Ev12mSalesRatio              float32 `json:"ev_12m_sales_ratio" parquet:"name=Ev12mSalesRatio, type=FLOAT"`
-	EvEbitda                     float32 `json:"ev_ebitda" parquet:"name=EvEbitda, type=FLOAT"`
-	PbRatio                      float32 `json:"pb_ratio" parquet:"name=PbRatio, type=FLOAT"`
-	PriceCfRatio                 float32 `json:"price_cf_ratio" parquet:"name=PriceCfRatio, type=FLOAT"`
-	RevenueGrowth                float32 `json:"revenue_growth" parquet:"name=RevenueGrowth, type=FLOAT"`
-	RevenueChange                float32 `json:"revenue_change_display" parquet:"name=RevenueChange, type=FLOAT"`
-	RevenueGrowth3               float32 `json:"revenue_growth3" parquet:"name=RevenueGrowth3, type=FLOAT"`
-	EbitdaYoy                    float32 `json:"ebitda_yoy" parquet:"name=EbitdaYoy, type=FLOAT"`
-	Ebitda3yCagr                 float32 `json:"ebitda_3y_cagr" parquet:"name=Ebitda3yCagr, type=FLOAT"`
-	NetIncome3yCagr              float32 `json:"net_income_3y_cagr" parquet:"name=NetIncome3yCagr, type=FLOAT"`
-	DilutedEpsGrowth             float32 `json:"diluted_eps_growth" parquet:"name=DilutedEpsGrowth, type=FLOAT"`
-	EarningsGrowth3yCagr         float32 `json:"earnings_growth_3y_cagr" parquet:"name=EarningsGrowth3yCagr, type=FLOAT"`
-	TangibleBookValue3yCagr      float32 `json:"tangible_book_value_3y_cagr" parquet:"name=TangibleBookValue3yCagr, type=FLOAT"`
-	TotalAssets3yCagr            float32 `json:"total_assets_3y_cagr" parquet:"name=TotalAssets3yCagr, type=FLOAT"`
-	TotalRevenue                 float64 `json:"total_revenue" parquet:"name=TotalRevenue, type=DOUBLE"`
-	NetIncome                    float64 `json:"net_income" parquet:"name=NetIncome, type=DOUBLE"`
-	CashFromOperationsAsReported float64 `json:"cash_from_operations_as_reported" parquet:"name=CashFromOperationsAsReported, type=DOUBLE"`
-	GrossMargin                  float32 `json:"gross_margin" parquet:"name=GrossMargin, type=FLOAT"`
-	EbitMargin                   float32 `json:"ebit_margin" parquet:"name=EbitMargin, type=FLOAT"`
-	EbitdaMargin                 float32 `json:"ebitda_margin" parquet:"name=EbitdaMargin, type=FLOAT"`
-	NetMargin                    float32 `json:"net_margin" parquet:"name=NetMargin, type=FLOAT"`
-	LeveredFcfMargin             float32 `json:"levered_fcf_margin" parquet:"name=LeveredFcfMargin, type=FLOAT"`
-	Roe                          float32 `json:"roe" parquet:"name=Roe, type=FLOAT"`
-	ReturnOnAvgTotAssets         float32 `json:"return_on_avg_tot_assets" parquet:"name=ReturnOnAvgTotAssets, type=FLOAT"`
-	ReturnOnTotalCapital         float32 `json:"return_on_total_capital" parquet:"name=ReturnOnTotalCapital, type=FLOAT"`
-	AssetsTurnover               float32 `json:"assets_turnover" parquet:"name=AssetsTurnover, type=FLOAT"`
-	NetIncPerEmployee            float64 `json:"net_inc_per_employee" parquet:"name=NetIncPerEmployee, type=DOUBLE"`
-	CapexToSales                 float32 `json:"capex_to_sales" parquet:"name=CapexToSales, type=FLOAT"`
-	ShortInterestPercentOfFloat  float32 `json:"short_interest_percent_of_float" parquet:"name=ShortInterestPercentOfFloat, type=FLOAT"`
-	ShortInterestCoverageRatio   float32 `json:"short_interest_coverage_ratio" parquet:"name=ShortInterestCoverageRatio, type=FLOAT"`
-	Beta24                       float32 `json:"beta24" parquet:"name=Beta24, type=FLOAT"`
-	AltmanZScore                 float32 `json:"altman_z_score" parquet:"name=AltmanZScore, type=FLOAT"`
-	Shares                       int64   `json:"shares" parquet:"name=Shares, type=INT64"`
-	FloatPercent                 float32 `json:"float_percent" parquet:"name=FloatPercent, type=FLOAT"`
-	InsidersShares               int64   `json:"insiders_shares" parquet:"name=InsidersShares, type=INT64"`
-	InsidersSharePercent         float64 `json:"insiders_share_percent" parquet:"name=InsidersSharePercent, type=DOUBLE"`
-	InstitutionsShares           int64   `json:"institutions_shares" parquet:"name=InstitutionsShares, type=INT64"`
-	InstitutionsSharePercent     float64 `json:"institutions_share_percent" parquet:"name=InstitutionsSharePercent, type=DOUBLE"`
-	TotalDebt                    float64 `json:"total_debt" parquet:"name=TotalDebt, type=DOUBLE"`
-	DebtLongTerm                 float64 `json:"debt_long_term" parquet:"name=DebtLongTerm, type=DOUBLE"`
-	TotalCash                    float64 `json:"total_cash" parquet:"name=TotalCash, type=DOUBLE"`
-	DebtFcf                      float32 `json:"debt_fcf" parquet:"name=DebtFcf, type=FLOAT"`
-	CurrentRatio                 float32 `json:"current_ratio" parquet:"name=CurrentRatio, type=FLOAT"`
-	QuickRatio                   float32 `json:"quick_ratio" parquet:"name=QuickRatio, type=FLOAT"`
-	InterestCoverageRatio        float32 `json:"interest_coverage_ratio" parquet:"name=InterestCoverageRatio, type=FLOAT"`
-	DebtEq                       float32 `json:"debt_eq" parquet:"name=DebtEq, type=FLOAT"`
-	LongTermDebtPerCapital       float32 `json:"long_term_debt_per_capital" parquet:"name=LongTermDebtPerCapital, type=FLOAT"`
-}
-
+	TickerId                     int                `json:"tickerId" parquet:"name=SeekingAlphaTickerId, type=INT32"`
+	Ticker                       string             `json:"ticker" parquet:"name=Ticker, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	CompositeFigi                string             `json:"compositeFigi" parquet:"name=CompositeFigi, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	CompanyName                  string             `json:"companyName" parquet:"name=CompanyName, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Exchange                     string             `json:"exchange" parquet:"name=Exchange, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Type                         string             `json:"type" parquet:"name=Type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	FollowersCount               int                `parquet:"name=FollowersCount, type=INT32"`
+	FetchedAt                    int64              `json:"fetchedAt" parquet:"name=FetchedAt, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	IsReit                       bool               `json:"isReit" parquet:"name=IsReit, type=BOOLEAN"`
+	IsBdc                        bool               `json:"isBdc" parquet:"name=IsBdc, type=BOOLEAN"`
+	IsDefunct                    bool               `json:"isDefunct" parquet:"name=IsDefunct, type=BOOLEAN"`
+	Sector                       string             `json:"sector" parquet:"name=Sector, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Industry                     string             `json:"industry" parquet:"name=Industry, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	MarketCap                    *float64           `json:"marketcap_display" parquet:"name=MarketCap, type=DOUBLE, repetitiontype=OPTIONAL"`
+	QuantRating                  *float32           `json:"quant_rating" parquet:"name=QuantRating, type=FLOAT, repetitiontype=OPTIONAL"`
+	AuthorsRatingPro             *float32           `json:"authors_rating" parquet:"name=AuthorsRatingPro, type=FLOAT, repetitiontype=OPTIONAL"`
+	SellSideRating               *float32           `json:"sell_side_rating" parquet:"name=SellSideRating, type=FLOAT, repetitiontype=OPTIONAL"`
+	ValueCategory                *float32           `json:"value_category" parquet:"name=ValueCategory, type=FLOAT, repetitiontype=OPTIONAL"`
+	GrowthCategory               *float32           `json:"growth_category" parquet:"name=GrowthCategory, type=FLOAT, repetitiontype=OPTIONAL"`
+	ProfitabilityCategory        *float32           `json:"profitability_category" parquet:"name=ProfitabilityCategory, type=FLOAT, repetitiontype=OPTIONAL"`
+	MomentumCategory             *float32           `json:"momentum_category" parquet:"name=MomentumCategory, type=FLOAT, repetitiontype=OPTIONAL"`
+	EpsRevisionsCategory         *float32           `json:"eps_revisions_category" parquet:"name=EpsRevisionsCategory, type=FLOAT, repetitiontype=OPTIONAL"`
+	DivSafetyCategory            *float32           `json:"div_safety_category" parquet:"name=DivSafetyCategory, type=FLOAT, repetitiontype=OPTIONAL"`
+	DivGrowthCategory            *float32           `json:"div_growth_category" parquet:"name=DivGrowthCategory, type=FLOAT, repetitiontype=OPTIONAL"`
+	DivYieldCategory             *float32           `json:"div_yield_category" parquet:"name=DivYieldCategory, type=FLOAT, repetitiontype=OPTIONAL"`
+	DivConsistencyCategory       *float32           `json:"div_consistency_category" parquet:"name=DivConsistencyCategory, type=FLOAT, repetitiontype=OPTIONAL"`
+	LastDivTimestamp             *int64             `json:"last_div_date" parquet:"name=LastDivTimestamp, type=INT64, repetitiontype=OPTIONAL"`
+	DivPayTimestamp              *int64             `json:"div_pay_date" parquet:"name=DivPayTimestamp, type=INT64, repetitiontype=OPTIONAL"`
+	DividendYield                *float32           `json:"dividend_yield" parquet:"name=DividendYield, type=FLOAT, repetitiontype=OPTIONAL"`
+	DivYieldFwd                  *float32           `json:"div_yield_fwd" parquet:"name=DivYieldFwd, type=FLOAT, repetitiontype=OPTIONAL"`
+	DivYield4y                   *float32           `json:"div_yield_4y" parquet:"name=DivYield4y, type=FLOAT, repetitiontype=OPTIONAL"`
+	DivRateTtm                   *float64           `json:"div_rate_ttm" parquet:"name=DivRateTtm, type=DOUBLE, repetitiontype=OPTIONAL"`
+	DivRateFwd                   *float64           `json:"div_rate_fwd" parquet:"name=DivRateFwd, type=DOUBLE, repetitiontype=OPTIONAL"`
+	PayoutRatio                  *float32           `json:"payout_ratio" parquet:"name=PayoutRatio, type=FLOAT, repetitiontype=OPTIONAL"`
+	PayoutRatio4y                *float32           `json:"payout_ratio_4y" parquet:"name=PayoutRatio4y, type=FLOAT, repetitiontype=OPTIONAL"`
+	DivGrowRate3                 *float32           `json:"div_grow_rate3" parquet:"name=DivGrowRate3, type=FLOAT, repetitiontype=OPTIONAL"`
+	DivGrowRate5                 *float32           `json:"div_grow_rate5" parquet:"name=DivGrowRate5, type=FLOAT, repetitiontype=OPTIONAL"`
+	DividendGrowth               *float32           `json:"dividend_growth" parquet:"name=DividendGrowth, type=FLOAT, repetitiontype=OPTIONAL"`
+	EarningAnnounceTimestamp     *int64             `json:"earning_announce_date" parquet:"name=EarningAnnounceTimestamp, type=INT64, repetitiontype=OPTIONAL"`
+	EpsEstimateFy1               *float64           `json:"eps_estimate_fy1" parquet:"name=EpsEstimateFy1, type=DOUBLE, repetitiontype=OPTIONAL"`
+	RevenueEstimate              *float64           `json:"revenue_estimate" parquet:"name=RevenueEstimate, type=DOUBLE, repetitiontype=OPTIONAL"`
+	EpsNormalizedActual          *float32           `json:"eps_normalized_actual" parquet:"name=EpsNormalizedActual, type=FLOAT, repetitiontype=OPTIONAL"`
+	EpsSurprise                  *float32           `json:"eps_surprise" parquet:"name=EpsSurprise, type=FLOAT, repetitiontype=OPTIONAL"`
+	RevenueActual                *float64           `json:"revenue_actual" parquet:"name=RevenueActual, type=DOUBLE, repetitiontype=OPTIONAL"`
+	RevenueSurprise              *float64           `json:"revenue_surprise" parquet:"name=RevenueSurprise, type=DOUBLE, repetitiontype=OPTIONAL"`
+	Tev                          *float64           `json:"tev" parquet:"name=Tev, type=DOUBLE, repetitiontype=OPTIONAL"`
+	PeRatio                      *float32           `json:"pe_ratio" parquet:"name=PeRatio, type=FLOAT, repetitiontype=OPTIONAL"`
+	PeNonGaapFy1                 *float32           `json:"pe_nongaap_fy1" parquet:"name=PeNonGaapFy1, type=FLOAT, repetitiontype=OPTIONAL"`
+	PsRatio                      *float32           `json:"ps_ratio" parquet:"name=PsRatio, type=FLOAT, repetitiontype=OPTIONAL"`
+	Ev12mSalesRatio              *float32           `json:"ev_12m_sales_ratio" parquet:"name=Ev12mSalesRatio, type=FLOAT, repetitiontype=OPTIONAL"`
+	EvEbitda                     *float32           `json:"ev_ebitda" parquet:"name=EvEbitda, type=FLOAT, repetitiontype=OPTIONAL"`
+	PbRatio                      *float32           `json:"pb_ratio" parquet:"name=PbRatio, type=FLOAT, repetitiontype=OPTIONAL"`
+	PriceCfRatio                 *float32           `json:"price_cf_ratio" parquet:"name=PriceCfRatio, type=FLOAT, repetitiontype=OPTIONAL"`
+	RevenueGrowth                *float32           `json:"revenue_growth" parquet:"name=RevenueGrowth, type=FLOAT, repetitiontype=OPTIONAL"`
+	RevenueChange                *float32           `json:"revenue_change_display" parquet:"name=RevenueChange, type=FLOAT, repetitiontype=OPTIONAL"`
+	RevenueGrowth3               *float32           `json:"revenue_growth3" parquet:"name=RevenueGrowth3, type=FLOAT, repetitiontype=OPTIONAL"`
+	EbitdaYoy                    *float32           `json:"ebitda_yoy" parquet:"name=EbitdaYoy, type=FLOAT, repetitiontype=OPTIONAL"`
+	Ebitda3yCagr                 *float32           `json:"ebitda_3y_cagr" parquet:"name=Ebitda3yCagr, type=FLOAT, repetitiontype=OPTIONAL"`
+	NetIncome3yCagr              *float32           `json:"net_income_3y_cagr" parquet:"name=NetIncome3yCagr, type=FLOAT, repetitiontype=OPTIONAL"`
+	DilutedEpsGrowth             *float32           `json:"diluted_eps_growth" parquet:"name=DilutedEpsGrowth, type=FLOAT, repetitiontype=OPTIONAL"`
+	EarningsGrowth3yCagr         *float32           `json:"earnings_growth_3y_cagr" parquet:"name=EarningsGrowth3yCagr, type=FLOAT, repetitiontype=OPTIONAL"`
+	TangibleBookValue3yCagr      *float32           `json:"tangible_book_value_3y_cagr" parquet:"name=TangibleBookValue3yCagr, type=FLOAT, repetitiontype=OPTIONAL"`
+	TotalAssets3yCagr            *float32           `json:"total_assets_3y_cagr" parquet:"name=TotalAssets3yCagr, type=FLOAT, repetitiontype=OPTIONAL"`
+	TotalRevenue                 *float64           `json:"total_revenue" parquet:"name=TotalRevenue, type=DOUBLE, repetitiontype=OPTIONAL"`
+	NetIncome                    *float64           `json:"net_income" parquet:"name=NetIncome, type=DOUBLE, repetitiontype=OPTIONAL"`
+	CashFromOperationsAsReported *float64           `json:"cash_from_operations_as_reported" parquet:"name=CashFromOperationsAsReported, type=DOUBLE, repetitiontype=OPTIONAL"`
+	GrossMargin                  *float32           `json:"gross_margin" parquet:"name=GrossMargin, type=FLOAT, repetitiontype=OPTIONAL"`
+	EbitMargin                   *float32           `json:"ebit_margin" parquet:"name=EbitMargin, type=FLOAT, repetitiontype=OPTIONAL"`
+	EbitdaMargin                 *float32           `json:"ebitda_margin" parquet:"name=EbitdaMargin, type=FLOAT, repetitiontype=OPTIONAL"`
+	NetMargin                    *float32           `json:"net_margin" parquet:"name=NetMargin, type=FLOAT, repetitiontype=OPTIONAL"`
+	LeveredFcfMargin             *float32           `json:"levered_fcf_margin" parquet:"name=LeveredFcfMargin, type=FLOAT, repetitiontype=OPTIONAL"`
+	Roe                          *float32           `json:"roe" parquet:"name=Roe, type=FLOAT, repetitiontype=OPTIONAL"`
+	ReturnOnAvgTotAssets         *float32           `json:"return_on_avg_tot_assets" parquet:"name=ReturnOnAvgTotAssets, type=FLOAT, repetitiontype=OPTIONAL"`
+	ReturnOnTotalCapital         *float32           `json:"return_on_total_capital" parquet:"name=ReturnOnTotalCapital, type=FLOAT, repetitiontype=OPTIONAL"`
+	AssetsTurnover               *float32           `json:"assets_turnover" parquet:"name=AssetsTurnover, type=FLOAT, repetitiontype=OPTIONAL"`
+	NetIncPerEmployee            *float64           `json:"net_inc_per_employee" parquet:"name=NetIncPerEmployee, type=DOUBLE, repetitiontype=OPTIONAL"`
+	CapexToSales                 *float32           `json:"capex_to_sales" parquet:"name=CapexToSales, type=FLOAT, repetitiontype=OPTIONAL"`
+	ShortInterestPercentOfFloat  *float32           `json:"short_interest_percent_of_float" parquet:"name=ShortInterestPercentOfFloat, type=FLOAT, repetitiontype=OPTIONAL"`
+	ShortInterestCoverageRatio   *float32           `json:"short_interest_coverage_ratio" parquet:"name=ShortInterestCoverageRatio, type=FLOAT, repetitiontype=OPTIONAL"`
+	Beta24                       *float32           `json:"beta24" parquet:"name=Beta24, type=FLOAT, repetitiontype=OPTIONAL"`
+	AltmanZScore                 *float32           `json:"altman_z_score" parquet:"name=AltmanZScore, type=FLOAT, repetitiontype=OPTIONAL"`
+	Shares                       *int64             `json:"shares" parquet:"name=Shares, type=INT64, repetitiontype=OPTIONAL"`
+	FloatPercent                 *float32           `json:"float_percent" parquet:"name=FloatPercent, type=FLOAT, repetitiontype=OPTIONAL"`
+	InsidersShares               *int64             `json:"insiders_shares" parquet:"name=InsidersShares, type=INT64, repetitiontype=OPTIONAL"`
+	InsidersSharePercent         *float64           `json:"insiders_share_percent" parquet:"name=InsidersSharePercent, type=DOUBLE, repetitiontype=OPTIONAL"`
+	InstitutionsShares           *int64             `json:"institutions_shares" parquet:"name=InstitutionsShares, type=INT64, repetitiontype=OPTIONAL"`
+	InstitutionsSharePercent     *float64           `json:"institutions_share_percent" parquet:"name=InstitutionsSharePercent, type=DOUBLE, repetitiontype=OPTIONAL"`
+	TotalDebt                    *float64           `json:"total_debt" parquet:"name=TotalDebt, type=DOUBLE, repetitiontype=OPTIONAL"`
+	DebtLongTerm                 *float64           `json:"debt_long_term" parquet:"name=DebtLongTerm, type=DOUBLE, repetitiontype=OPTIONAL"`
+	TotalCash                    *float64           `json:"total_cash" parquet:"name=TotalCash, type=DOUBLE, repetitiontype=OPTIONAL"`
+	DebtFcf                      *float32           `json:"debt_fcf" parquet:"name=DebtFcf, type=FLOAT, repetitiontype=OPTIONAL"`
+	CurrentRatio                 *float32           `json:"current_ratio" parquet:"name=CurrentRatio, type=FLOAT, repetitiontype=OPTIONAL"`
+	QuickRatio                   *float32           `json:"quick_ratio" parquet:"name=QuickRatio, type=FLOAT, repetitiontype=OPTIONAL"`
+	InterestCoverageRatio        *float32           `json:"interest_coverage_ratio" parquet:"name=InterestCoverageRatio, type=FLOAT, repetitiontype=OPTIONAL"`
+	DebtEq                       *float32           `json:"debt_eq" parquet:"name=DebtEq, type=FLOAT, repetitiontype=OPTIONAL"`
+	LongTermDebtPerCapital       *float32           `json:"long_term_debt_per_capital" parquet:"name=LongTermDebtPerCapital, type=FLOAT, repetitiontype=OPTIONAL"`
+	Extras                       map[string]float64 `parquet:"name=Extras, type=MAP, convertedtype=MAP, keytype=BYTE_ARRAY, keyconvertedtype=UTF8, valuetype=DOUBLE"`
+	MissingReasons               map[string]string  `parquet:"name=MissingReasons, type=MAP, convertedtype=MAP, keytype=BYTE_ARRAY, keyconvertedtype=UTF8, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+}
+
+// FilterDef restricts a screener field to a range (gte, lte) or a list of
+// values (in); exclude inverts the filter
 type FilterDef struct {
-	Gte     int  `json:"gte"`
-	Lte     int  `json:"lte"`
-	Exclude bool `json:"exclude"`
+	Gte     *float64 `json:"gte,omitempty" mapstructure:"gte"`
+	Lte     *float64 `json:"lte,omitempty" mapstructure:"lte"`
+	In      []string `json:"in,omitempty" mapstructure:"in"`
+	Exclude bool     `json:"exclude" mapstructure:"exclude"`
 }
 
-type FilterGroup struct {
-	QuantRating FilterDef `json:"quant_rating"`
-	// AuthorsRatingPro FilterDef `json:"authors_rating"`
-	// SellSideRating   FilterDef `json:"sell_side_rating"`
-}
+// FilterGroup maps screener field names (e.g. quant_rating, authors_rating,
+// sell_side_rating, marketcap_display, sectors) to their filter
+type FilterGroup map[string]FilterDef
 
 type ScreenerArguments struct {
 	Filter     FilterGroup `json:"filter"`
@@ -199,6 +226,10 @@ func (record *SeekingAlphaRecord) MarshalZerologObject(e *zerolog.Event) {
 	e.Str("Ticker", record.Ticker)
 	e.Str("CompositeFigi", record.CompositeFigi)
 	e.Time("EventDate", record.Date)
-	e.Float32("QuantRating", record.QuantRating)
-	e.Float64("MarketCapMil", record.MarketCap)
+	if record.QuantRating != nil {
+		e.Float32("QuantRating", *record.QuantRating)
+	}
+	if record.MarketCap != nil {
+		e.Float64("MarketCapMil", *record.MarketCap)
+	}
 }
@@ -26,12 +26,12 @@ func ValidateRatings(records []*SeekingAlphaRecord) {
 	var sumValue float32 = 0.0
 
 	for _, record := range records {
-		sumQuant += record.QuantRating
-		sumGrowth += record.GrowthCategory
-		sumRevisions += record.EpsRevisionsCategory
-		sumMomentum += record.MomentumCategory
-		sumProfit += record.ProfitabilityCategory
-		sumValue += record.ValueCategory
+		sumQuant += deref(record.QuantRating)
+		sumGrowth += deref(record.GrowthCategory)
+		sumRevisions += deref(record.EpsRevisionsCategory)
+		sumMomentum += deref(record.MomentumCategory)
+		sumProfit += deref(record.ProfitabilityCategory)
+		sumValue += deref(record.ValueCategory)
 	}
 
 	if sumQuant < 1 {
@@ -59,3 +59,23 @@ func ValidateRatings(records []*SeekingAlphaRecord) {
 	}
 
 }
+
+// ValidateEtfRatings checks that the ETF ratings were populated
+func ValidateEtfRatings(records []*EtfRecord) {
+	log.Info().Msg("validating downloaded ETF ratings fields have non-zero values")
+	var sumQuant float32 = 0.0
+	var sumMomentum float32 = 0.0
+
+	for _, record := range records {
+		sumQuant += deref(record.QuantRating)
+		sumMomentum += deref(record.MomentumCategory)
+	}
+
+	if sumQuant < 1 {
+		log.Fatal().Float32("sumQuant", sumQuant).Msg("quant_rating field is 0 for all ETF records")
+	}
+
+	if sumMomentum < 1 {
+		log.Fatal().Float32("sumMomentum", sumMomentum).Msg("momentum_category field is 0 for all ETF records")
+	}
+}